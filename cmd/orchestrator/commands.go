@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	ui "github.com/joseph-gunnarsson/go-replicate-local/internal/interface"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+// replayLines caps how much stored history is redrawn when the log filter
+// changes.
+const replayLines = 2000
+
+// replayHistory switches the log filter and redraws the log view from the
+// runner's stored history for the new filter.
+func replayHistory(r *runner.Runner, out console, filter runner.LogQuery) {
+	r.SetLogFilter(filter, replayLines, func(entries []runner.LogEntry) {
+		out.Replay(filter, entries)
+	})
+}
+
+func handleCommand(input string, r *runner.Runner, out console, cancel context.CancelFunc) {
+	parts := strings.Fields(input)
+	if len(parts) == 0 {
+		return
+	}
+
+	cmd := strings.ToLower(parts[0])
+	args := parts[1:]
+
+	switch cmd {
+	case "help":
+		out.Info(ui.HelpText())
+
+	case "list":
+		replicas := r.ListReplicas()
+		out.Info(ui.FormatReplicaList(replicas))
+
+	case "isolate":
+		if len(args) < 1 {
+			out.Error("Usage: isolate <replica-name>")
+			return
+		}
+		replicaName := args[0]
+		if !r.HasReplica(replicaName) {
+			out.Error(fmt.Sprintf("Replica '%s' not found", replicaName))
+			return
+		}
+		filter := r.LogFilter()
+		filter.Replica = replicaName
+		replayHistory(r, out, filter)
+		out.Success(fmt.Sprintf("Now showing logs only from: %s", replicaName))
+
+	case "showall":
+		filter := r.LogFilter()
+		filter.Replica = ""
+		replayHistory(r, out, filter)
+		out.Success("Now showing logs from all replicas")
+
+	case "level":
+		if len(args) < 1 {
+			out.Error("Usage: level <debug|info|warn|error|all>")
+			return
+		}
+		filter := r.LogFilter()
+		if strings.EqualFold(args[0], "all") {
+			filter.MinLevel = runner.LevelUnknown
+		} else {
+			level, ok := runner.ParseLevel(args[0])
+			if !ok {
+				out.Error(fmt.Sprintf("Unknown log level: %s", args[0]))
+				return
+			}
+			filter.MinLevel = level
+		}
+		replayHistory(r, out, filter)
+		if filter.MinLevel == runner.LevelUnknown {
+			out.Success("Showing lines of every level")
+		} else {
+			out.Success(fmt.Sprintf("Showing lines at %s and above", filter.MinLevel))
+		}
+
+	case "grep":
+		// The pattern is everything after the command so it may contain spaces.
+		expr := strings.TrimSpace(strings.TrimSpace(input)[len(parts[0]):])
+		filter := r.LogFilter()
+		if expr == "" {
+			filter.Pattern = nil
+			replayHistory(r, out, filter)
+			out.Success("Search cleared")
+			return
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			out.Error(fmt.Sprintf("Invalid regex: %v", err))
+			return
+		}
+		filter.Pattern = pattern
+		replayHistory(r, out, filter)
+		out.Success(fmt.Sprintf("Showing lines matching %s", pattern))
+
+	case "stop":
+		if len(args) < 1 {
+			out.Error("Usage: stop <replica-name>")
+			return
+		}
+		replicaName := args[0]
+		out.Run(func() {
+			if err := r.StopReplica(replicaName); err != nil {
+				out.Error(err.Error())
+			} else {
+				out.Success(fmt.Sprintf("Stopped replica: %s", replicaName))
+			}
+		})
+
+	case "restart":
+		if len(args) < 1 {
+			out.Error("Usage: restart <replica-name|service>")
+			return
+		}
+		target := args[0]
+		out.Run(func() {
+			var err error
+			if r.HasService(target) {
+				err = r.RestartService(target)
+			} else {
+				err = r.RestartReplica(target)
+			}
+			if err != nil {
+				out.Error(err.Error())
+			} else {
+				out.Success(fmt.Sprintf("Restarted %s", target))
+			}
+		})
+
+	case "rollout":
+		if len(args) < 1 {
+			out.Error("Usage: rollout <service>")
+			return
+		}
+		serviceName := args[0]
+		out.Run(func() {
+			if err := r.Rollout(serviceName); err != nil {
+				out.Error(err.Error())
+			} else {
+				out.Success(fmt.Sprintf("Rolled out %s", serviceName))
+			}
+		})
+
+	case "kill":
+		sig := syscall.SIGTERM
+		if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			parsed, err := config.ParseSignal(args[0][1:])
+			if err != nil {
+				out.Error(err.Error())
+				return
+			}
+			sig = parsed
+			args = args[1:]
+		}
+		if len(args) < 1 {
+			out.Error("Usage: kill [-SIGNAL] <replica-name>")
+			return
+		}
+		replicaName := args[0]
+		if err := r.KillReplica(replicaName, sig); err != nil {
+			out.Error(err.Error())
+		} else {
+			out.Success(fmt.Sprintf("Sent %s to replica: %s", config.SignalName(sig), replicaName))
+		}
+
+	case "scale":
+		if len(args) < 2 {
+			out.Error("Usage: scale <service> <replicas>")
+			return
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			out.Error(fmt.Sprintf("Invalid replica count: %s", args[1]))
+			return
+		}
+		serviceName := args[0]
+		out.Run(func() {
+			if err := r.Scale(serviceName, n); err != nil {
+				out.Error(err.Error())
+			} else {
+				out.Success(fmt.Sprintf("Scaled %s to %d replicas", serviceName, n))
+			}
+		})
+
+	case "quit", "exit":
+		out.Info("[Sim] Shutting down...")
+		r.ShutdownAll()
+		cancel()
+		out.Quit()
+
+	default:
+		out.Error(fmt.Sprintf("Unknown command: %s (type 'help' for available commands)", cmd))
+	}
+}
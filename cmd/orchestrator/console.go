@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	ui "github.com/joseph-gunnarsson/go-replicate-local/internal/interface"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+// console is where command results, startup progress and replica logs are
+// shown: the TUI, or stdout when running headless.
+type console interface {
+	Info(msg string)
+	Success(msg string)
+	Error(msg string)
+	LogEntry(e runner.LogEntry)
+	Replay(filter runner.LogQuery, entries []runner.LogEntry)
+	// Run runs a slow command such as a scale or rollout. The TUI runs it in
+	// the background so input stays responsive; the control socket waits so
+	// the client sees the result.
+	Run(f func())
+	Quit()
+}
+
+// tuiConsole sends everything shown in the log view through msgs, so
+// replays stay ordered with the lines around them.
+type tuiConsole struct {
+	program *ui.Program
+	msgs    chan<- ui.Msg
+}
+
+func (c tuiConsole) Info(msg string)    { c.msgs <- ui.LogMsg(msg) }
+func (c tuiConsole) Success(msg string) { c.msgs <- ui.LogMsg(ui.FormatSuccess(msg)) }
+func (c tuiConsole) Error(msg string)   { c.msgs <- ui.LogMsg(ui.FormatError(msg)) }
+
+func (c tuiConsole) LogEntry(e runner.LogEntry) { c.msgs <- ui.LogEntryMsg(e) }
+
+func (c tuiConsole) Replay(filter runner.LogQuery, entries []runner.LogEntry) {
+	c.msgs <- ui.ReplayMsg{Filter: filter, Entries: entries}
+}
+
+func (c tuiConsole) Run(f func()) { go f() }
+
+func (c tuiConsole) Quit() { c.program.Quit() }
+
+// stdoutConsole writes one line per message, either as prefixed text or as
+// a JSON object per line. Colours in messages, such as those of the replica
+// list, are stripped.
+type stdoutConsole struct {
+	w    io.Writer
+	json bool
+	quit func()
+	mu   sync.Mutex
+}
+
+func newStdoutConsole(w io.Writer, jsonLines bool, quit func()) *stdoutConsole {
+	return &stdoutConsole{w: w, json: jsonLines, quit: quit}
+}
+
+func (c *stdoutConsole) Info(msg string)    { c.message("info", "", msg) }
+func (c *stdoutConsole) Success(msg string) { c.message("info", "✓ ", msg) }
+func (c *stdoutConsole) Error(msg string)   { c.message("error", "✗ ", msg) }
+
+func (c *stdoutConsole) message(level, mark, msg string) {
+	msg = strings.TrimSpace(ansiEscape.ReplaceAllString(msg, ""))
+	if !c.json {
+		c.writeLine(mark + msg)
+		return
+	}
+	c.writeJSON(map[string]any{
+		"time":   time.Now().Format(time.RFC3339Nano),
+		"source": "sim",
+		"level":  level,
+		"msg":    msg,
+	})
+}
+
+func (c *stdoutConsole) LogEntry(e runner.LogEntry) {
+	if !c.json {
+		c.writeLine(e.Line())
+		return
+	}
+	obj := map[string]any{
+		"time":    e.Time.Format(time.RFC3339Nano),
+		"seq":     e.Seq,
+		"source":  "replica",
+		"replica": e.Replica,
+		"stream":  strings.ToLower(string(e.Stream)),
+		"msg":     e.Message,
+	}
+	if e.Level != runner.LevelUnknown {
+		obj["level"] = strings.ToLower(e.Level.String())
+	}
+	if len(e.Fields) > 0 {
+		fields := make(map[string]string, len(e.Fields))
+		for _, f := range e.Fields {
+			fields[f.Key] = f.Value
+		}
+		obj["fields"] = fields
+	}
+	c.writeJSON(obj)
+}
+
+// Replay is a no-op: lines already written to stdout can't be redrawn.
+func (c *stdoutConsole) Replay(runner.LogQuery, []runner.LogEntry) {}
+
+func (c *stdoutConsole) Run(f func()) { go f() }
+
+func (c *stdoutConsole) Quit() {
+	if c.quit != nil {
+		c.quit()
+	}
+}
+
+func (c *stdoutConsole) writeLine(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintln(c.w, line)
+}
+
+func (c *stdoutConsole) writeJSON(obj map[string]any) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.w.Write(append(b, '\n'))
+}
+
+// consoleWriter lets the standard logger, used for [Sim] and [LB] messages,
+// write to a console.
+type consoleWriter struct {
+	out console
+}
+
+func (w consoleWriter) Write(p []byte) (int, error) {
+	w.out.Info(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+func TestStdoutConsoleText(t *testing.T) {
+	var buf bytes.Buffer
+	out := newStdoutConsole(&buf, false, nil)
+
+	out.Info("\x1b[1mNAME\x1b[0m   \x1b[32mready\x1b[0m\n")
+	out.Success("Scaled api to 2 replicas")
+	out.Error("no such replica api-9")
+	out.LogEntry(runner.LogEntry{Replica: "api-1", Stream: runner.StreamStdout, Raw: "hello", Message: "hello"})
+
+	want := "NAME   ready\n" +
+		"✓ Scaled api to 2 replicas\n" +
+		"✗ no such replica api-9\n" +
+		"[STDOUT][api-1] hello\n"
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestStdoutConsoleJSON(t *testing.T) {
+	var buf bytes.Buffer
+	out := newStdoutConsole(&buf, true, nil)
+
+	out.Error("\x1b[31mbuild failed\x1b[0m")
+	out.LogEntry(runner.LogEntry{
+		Seq:        7,
+		Time:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Replica:    "api-1",
+		Stream:     runner.StreamStderr,
+		Structured: true,
+		Level:      runner.LevelWarn,
+		Message:    "slow request",
+		Fields:     []runner.LogField{{Key: "ms", Value: "250"}},
+	})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one JSON object per line, got %q", buf.String())
+	}
+	var msg, entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg["source"] != "sim" || msg["level"] != "error" || msg["msg"] != "build failed" {
+		t.Errorf("unexpected message object %v", msg)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"time":    "2024-05-01T12:00:00Z",
+		"seq":     float64(7),
+		"source":  "replica",
+		"replica": "api-1",
+		"stream":  "stderr",
+		"level":   "warn",
+		"msg":     "slow request",
+		"fields":  map[string]any{"ms": "250"},
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("expected %v, got %v", want, entry)
+	}
+}
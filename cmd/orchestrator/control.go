@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+// The control socket speaks a line protocol: the client sends one command
+// line and the orchestrator answers with lines tagged by kind until the
+// command is done, then closes the connection.
+const (
+	tagInfo    = "I"
+	tagSuccess = "S"
+	tagError   = "E"
+)
+
+const defaultLogTail = 100
+
+func defaultControlSocket() string {
+	return filepath.Join(os.TempDir(), "go-sim.sock")
+}
+
+// listenControl opens the control socket at path.
+func listenControl(path string) (net.Listener, error) {
+	// A socket file left behind by a crashed instance would make Listen
+	// fail; only remove it if nothing answers on it.
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another instance is listening on %s", path)
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		os.Remove(path)
+	}
+	return net.Listen("unix", path)
+}
+
+// serveControl accepts control connections until ln is closed. Commands run
+// through handleCommand; host is the orchestrator's own console, used for
+// redraws and quitting. Connections still following logs are closed once ctx
+// is done.
+func serveControl(ctx context.Context, ln net.Listener, r *runner.Runner, host console, cancel context.CancelFunc) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[Sim] Control socket stopped: %v", err)
+			}
+			return
+		}
+		go handleControlConn(ctx, conn, r, host, cancel)
+	}
+}
+
+func handleControlConn(ctx context.Context, conn net.Conn, r *runner.Runner, host console, cancel context.CancelFunc) {
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	out := &socketConsole{w: conn, host: host}
+
+	parts := strings.Fields(line)
+	if len(parts) > 0 && parts[0] == "logs" {
+		followLogs(ctx, conn, r, out, parts[1:])
+		return
+	}
+	handleCommand(line, r, out, cancel)
+}
+
+// followLogs answers `logs [-f] [-n lines] [replica]`: the last lines of
+// stored history and, with -f, new lines until the client hangs up or ctx is
+// done.
+func followLogs(ctx context.Context, conn net.Conn, r *runner.Runner, out *socketConsole, args []string) {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	follow := fs.Bool("f", false, "")
+	tail := fs.Int("n", defaultLogTail, "")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 1 {
+		out.Error("Usage: logs [-f] [-n lines] [replica]")
+		return
+	}
+	q := runner.LogQuery{Replica: fs.Arg(0), Limit: *tail}
+	if q.Replica != "" && !r.HasReplica(q.Replica) {
+		out.Error(fmt.Sprintf("Replica '%s' not found", q.Replica))
+		return
+	}
+
+	if !*follow {
+		for _, e := range r.GetLogs(q) {
+			out.Info(e.Line())
+		}
+		return
+	}
+
+	// The client never sends anything after its command, so a read only
+	// returns once it has hung up.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, conn)
+		cancel()
+	}()
+
+	r.FollowLogs(ctx, q, func(e runner.LogEntry) error {
+		out.Info(e.Line())
+		return nil
+	})
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// socketConsole sends a command's output back over a control connection.
+// Redraws and quitting are passed on to the orchestrator's own console.
+type socketConsole struct {
+	w    io.Writer
+	host console
+	mu   sync.Mutex
+}
+
+func (c *socketConsole) Info(msg string)    { c.send(tagInfo, msg) }
+func (c *socketConsole) Success(msg string) { c.send(tagSuccess, msg) }
+func (c *socketConsole) Error(msg string)   { c.send(tagError, msg) }
+
+func (c *socketConsole) LogEntry(runner.LogEntry) {}
+
+func (c *socketConsole) Replay(filter runner.LogQuery, entries []runner.LogEntry) {
+	c.host.Replay(filter, entries)
+}
+
+func (c *socketConsole) Run(f func()) { f() }
+
+func (c *socketConsole) Quit() { c.host.Quit() }
+
+func (c *socketConsole) send(tag, msg string) {
+	msg = strings.Trim(ansiEscape.ReplaceAllString(msg, ""), "\n")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, line := range strings.Split(msg, "\n") {
+		fmt.Fprintf(c.w, "%s %s\n", tag, line)
+	}
+}
+
+// runClient sends a subcommand to the orchestrator listening on socket and
+// prints its answer to stdout and errors to stderr, returning the exit code:
+// 1 if the command failed.
+func runClient(socket string, args []string, stdout, stderr io.Writer) int {
+	command := strings.Join(args, " ")
+	if args[0] == "status" {
+		command = "list"
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		fmt.Fprintf(stderr, "Cannot reach a running go-sim on %s: %v\n", socket, err)
+		return 1
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		fmt.Fprintf(stderr, "Failed to send command: %v\n", err)
+		return 1
+	}
+
+	code := 0
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		tag, text, _ := strings.Cut(scanner.Text(), " ")
+		switch tag {
+		case tagError:
+			fmt.Fprintln(stderr, "✗ "+text)
+			code = 1
+		case tagSuccess:
+			fmt.Fprintln(stdout, "✓ "+text)
+		default:
+			fmt.Fprintln(stdout, text)
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		fmt.Fprintf(stderr, "Connection error: %v\n", err)
+		return 1
+	}
+	return code
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+// converse sends line over a control connection served by handleControlConn
+// and returns everything written back until the connection is closed.
+func converse(t *testing.T, ctx context.Context, line string) string {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+
+	host := newStdoutConsole(io.Discard, false, func() {})
+	go handleControlConn(ctx, server, runner.NewRunner(), host, func() {})
+
+	if _, err := io.WriteString(client, line+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(reply)
+}
+
+func TestControlProtocolTags(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		command string
+		want    string
+	}{
+		{"help", "I │  help              Show this help message        │\n"},
+		{"list", "I No replicas.\n"},
+		{"kill missing-1", "E replica missing-1 not found\n"},
+		{"frobnicate", "E Unknown command: frobnicate"},
+		{"logs -x", "E Usage: logs [-f] [-n lines] [replica]\n"},
+		{"logs -n ten", "E Usage: logs [-f] [-n lines] [replica]\n"},
+		{"logs a-1 b-1", "E Usage: logs [-f] [-n lines] [replica]\n"},
+		{"logs -n 5 missing-1", "E Replica 'missing-1' not found\n"},
+	} {
+		if got := converse(t, ctx, tc.command); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: expected %q in reply, got %q", tc.command, tc.want, got)
+		}
+	}
+}
+
+func TestControlFollowEndsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan string)
+	go func() { done <- converse(t, ctx, "logs -f") }()
+
+	select {
+	case <-done:
+		t.Fatal("logs -f returned before the context was cancelled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("logs -f kept the connection open after the context was cancelled")
+	}
+}
+
+// fakeOrchestrator answers a single control connection on a fresh socket
+// with reply and reports the command it received.
+func fakeOrchestrator(t *testing.T, reply string) (socket string, received <-chan string) {
+	t.Helper()
+	socket = filepath.Join(t.TempDir(), "go-sim.sock")
+	ln, err := listenControl(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	commands := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		commands <- strings.TrimSpace(line)
+		io.WriteString(conn, reply)
+	}()
+	return socket, commands
+}
+
+func TestRunClient(t *testing.T) {
+	socket, received := fakeOrchestrator(t, "I Replicas (1):\nS Done\n")
+	var stdout, stderr strings.Builder
+	if code := runClient(socket, []string{"status"}, &stdout, &stderr); code != 0 {
+		t.Errorf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+	if cmd := <-received; cmd != "list" {
+		t.Errorf("expected status to be sent as list, got %q", cmd)
+	}
+	if stdout.String() != "Replicas (1):\n✓ Done\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+
+	socket, received = fakeOrchestrator(t, "I Stopping\nE Replica 'a-9' not found\n")
+	stdout.Reset()
+	stderr.Reset()
+	if code := runClient(socket, []string{"kill", "-9", "a-9"}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 after an error line, got %d", code)
+	}
+	if cmd := <-received; cmd != "kill -9 a-9" {
+		t.Errorf("unexpected command %q", cmd)
+	}
+	if stderr.String() != "✗ Replica 'a-9' not found\n" {
+		t.Errorf("unexpected stderr %q", stderr.String())
+	}
+
+	if code := runClient(filepath.Join(t.TempDir(), "none.sock"), []string{"status"}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 without an orchestrator, got %d", code)
+	}
+}
+
+func TestListenControlStaleSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "go-sim.sock")
+
+	// Leave a socket file behind like a crashed instance would.
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listenControl(socket)
+	if err != nil {
+		t.Fatalf("stale socket not replaced: %v", err)
+	}
+	defer ln.Close()
+
+	if _, err := listenControl(socket); err == nil || !strings.Contains(err.Error(), "another instance") {
+		t.Errorf("expected a live socket to be refused, got %v", err)
+	}
+
+	// Anything that isn't a socket is left alone.
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(file, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenControl(file); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("expected a regular file to be refused, got %v", err)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "keep me" {
+		t.Errorf("expected the regular file to be kept, got %q, %v", data, err)
+	}
+}
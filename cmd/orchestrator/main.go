@@ -2,85 +2,186 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/admin"
 	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
 	ui "github.com/joseph-gunnarsson/go-replicate-local/internal/interface"
 	"github.com/joseph-gunnarsson/go-replicate-local/internal/lb"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/registry"
 	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
 )
 
 type logWriter struct {
-	logChan chan<- string
+	logChan chan<- ui.Msg
 }
 
 func (w *logWriter) Write(p []byte) (n int, err error) {
 	msg := string(p)
 	msg = strings.TrimSuffix(msg, "\n")
-	w.logChan <- msg
+	w.logChan <- ui.LogMsg(msg)
 	return len(p), nil
 }
 
 func main() {
 	configFile := flag.String("config", "simulation.yaml", "Path to configuration file")
+	headless := flag.Bool("headless", false, "Run without the TUI and write logs to stdout")
+	logFormat := flag.String("log-format", "text", "Format of headless output: text or json")
+	socket := flag.String("socket", "", "Control socket path (default: control_socket from the config, else "+defaultControlSocket()+")")
+	flag.Usage = usage
 	flag.Parse()
 
+	// Any arguments left are a subcommand for an instance that is already
+	// running, e.g. `go-sim scale auth-service 3`.
+	if flag.NArg() > 0 {
+		if *socket == "" {
+			if cfg, err := config.LoadConfig(*configFile); err == nil {
+				*socket = cfg.ControlSocket
+			}
+		}
+		if *socket == "" {
+			*socket = defaultControlSocket()
+		}
+		os.Exit(runClient(*socket, flag.Args(), os.Stdout, os.Stderr))
+	}
+
+	if *logFormat != "text" && *logFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown log format %q (want text or json)\n", *logFormat)
+		os.Exit(2)
+	}
+
 	cfg, err := config.LoadConfig(*configFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
 		os.Exit(1)
 	}
 
+	reg := registry.New()
+
+	r := runner.NewRunner()
+	r.SetRegistry(reg)
+	if cfg.BuildCacheDir != "" {
+		r.SetBuildCacheDir(cfg.BuildCacheDir)
+	}
+	r.SetLogLimits(cfg.LogBuffer.PerReplica, cfg.LogBuffer.Total)
+	if cfg.LogDir != "" {
+		if err := r.SetLogDir(cfg.LogDir, int64(cfg.LogMaxSizeMB)<<20, cfg.LogMaxFiles); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set up log dir: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *socket != "" {
+		cfg.ControlSocket = *socket
+	}
+	if cfg.ControlSocket == "" {
+		cfg.ControlSocket = defaultControlSocket()
+	}
+
+	if *headless {
+		os.Exit(runHeadless(cfg, reg, r, *logFormat == "json"))
+	}
+	runTUI(cfg, reg, r)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, `Usage:
+  go-sim [flags]                     run the simulation
+  go-sim [flags] status              list replicas of a running simulation
+  go-sim [flags] kill [-SIG] <name>  signal a replica
+  go-sim [flags] scale <svc> <n>     scale a service
+  go-sim [flags] logs [-f] [-n N] [replica]
+                                     print (and follow) stored logs
+  go-sim [flags] <command>           run any TUI command, e.g. restart, rollout
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func runTUI(cfg config.Config, reg *registry.Registry, r *runner.Runner) {
 	logChan, cmdChan, program := ui.Setup()
+	out := tuiConsole{program: program, msgs: logChan}
 
 	log.SetOutput(&logWriter{logChan: logChan})
-	log.SetFlags(0) 
+	log.SetFlags(0)
 
-	r := runner.NewRunner()
-	r.SetLogCallback(func(msg string) {
-		logChan <- msg
-	})
+	// Replica lines share logChan with the [Sim] and [LB] lines so that they
+	// stay ordered with the history replayed by isolate.
+	r.SetLogCallback(out.LogEntry)
+
+	balancer, err := lb.NewLoadBalancer(cfg, reg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up load balancer: %v\n", err)
+		os.Exit(1)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go func() {
+	startAdmin(ctx, cfg, r, balancer, out)
+	stopControl := startControl(ctx, cfg, r, out, cancel)
+	defer stopControl()
 
-		for _, svc := range cfg.Services {
-			if err := r.StartService(ctx, svc); err != nil {
-				ui.SendLog(program, ui.FormatError(fmt.Sprintf("Failed to start %s: %v", svc.Name, err)))
-			} else {
-				ui.SendLog(program, ui.FormatSuccess(fmt.Sprintf("Started service: %s (%d replicas)", svc.Name, svc.Replicas)))
-			}
+	// The load balancer runs before any service starts so that dependents
+	// can reach their dependencies through it while later tiers start up.
+	go func() {
+		if err := balancer.Run(ctx); err != nil {
+			out.Error(fmt.Sprintf("Load Balancer failed: %v", err))
+			cancel()
 		}
+	}()
+	out.Success(fmt.Sprintf("Load balancer started on port %d", cfg.LBPort))
 
-		go func() {
-			if err := lb.StartLB(ctx, cfg); err != nil {
-				ui.SendLog(program, ui.FormatError(fmt.Sprintf("Load Balancer failed: %v", err)))
-				cancel()
-			}
-		}()
-		ui.SendLog(program, ui.FormatSuccess(fmt.Sprintf("Load balancer started on port %d", cfg.LBPort)))
+	// Catch signals before starting anything, so that an interrupted startup
+	// still stops the replicas it already started.
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		if err := startServices(ctx, cfg, r, out); err != nil && ctx.Err() == nil {
+			out.Error("Startup failed: not every service could be started")
+		}
+	}()
 
+	go func() {
 		<-sigChan
-		ui.SendLog(program, "\n[Sim] Received shutdown signal, stopping all replicas...")
-		cancel() 
+		out.Info("\n[Sim] Received shutdown signal, stopping all replicas...")
+		cancel()
+		<-started
 		r.ShutdownAll()
 		program.Quit()
 	}()
 
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			ui.SendReplicas(program, r.ListReplicas())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
 	go func() {
 		for cmd := range cmdChan {
-			handleCommand(cmd, r, program, cancel)
+			handleCommand(cmd, r, out, cancel)
 		}
 	}()
 
@@ -88,74 +189,199 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error running TUI: %v\n", err)
 		os.Exit(1)
 	}
-	
+
 	cancel()
+	<-started
 	r.ShutdownAll()
+	r.CloseLogs()
 }
 
-func handleCommand(input string, r *runner.Runner, program *ui.Program, cancel context.CancelFunc) {
-	parts := strings.Fields(input)
-	if len(parts) == 0 {
-		return
-	}
+// runHeadless runs the simulation without the TUI until SIGINT or SIGTERM
+// and returns the process exit code: non-zero if a service fails to build,
+// start or become ready, or if the load balancer fails.
+func runHeadless(cfg config.Config, reg *registry.Registry, r *runner.Runner, jsonLogs bool) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	cmd := strings.ToLower(parts[0])
-	args := parts[1:]
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	switch cmd {
-	case "help":
-		ui.SendLog(program, ui.HelpText())
+	out := newStdoutConsole(os.Stdout, jsonLogs, func() {
+		select {
+		case sigChan <- syscall.SIGTERM:
+		default:
+		}
+	})
+	log.SetOutput(consoleWriter{out: out})
+	log.SetFlags(0)
+	r.SetLogCallback(out.LogEntry)
 
-	case "list":
-		replicas := r.ListReplicas()
-		ui.SendLog(program, ui.FormatReplicaList(replicas))
+	balancer, err := lb.NewLoadBalancer(cfg, reg)
+	if err != nil {
+		out.Error(fmt.Sprintf("Failed to set up load balancer: %v", err))
+		return 1
+	}
+	startAdmin(ctx, cfg, r, balancer, out)
+	stopControl := startControl(ctx, cfg, r, out, cancel)
+	defer stopControl()
 
-	case "isolate":
-		if len(args) < 1 {
-			ui.SendLog(program, ui.FormatError("Usage: isolate <replica-name>"))
+	shutdown := func(code int) int {
+		cancel()
+		r.ShutdownAll()
+		r.CloseLogs()
+		return code
+	}
+
+	// As in the TUI, the load balancer comes up first so that dependents can
+	// reach their dependencies through it during startup.
+	lbErr := make(chan error, 1)
+	go func() {
+		lbErr <- balancer.Run(ctx)
+	}()
+	out.Success(fmt.Sprintf("Load balancer started on port %d", cfg.LBPort))
+
+	started := make(chan error, 1)
+	go func() {
+		if err := startServices(ctx, cfg, r, out); err != nil {
+			started <- errors.New("not every service could be started")
 			return
 		}
-		replicaName := args[0]
-		replicas := r.ListReplicas()
-		found := false
-		for _, rn := range replicas {
-			if rn == replicaName {
-				found = true
-				break
-			}
+		started <- waitForServices(ctx, cfg, r)
+	}()
+
+	select {
+	case <-sigChan:
+		out.Info("[Sim] Received shutdown signal during startup, stopping all replicas...")
+		cancel()
+		<-started
+		return shutdown(1)
+	case err := <-lbErr:
+		out.Error(fmt.Sprintf("Load Balancer failed: %v", err))
+		cancel()
+		<-started
+		return shutdown(1)
+	case err := <-started:
+		if err != nil {
+			out.Error(fmt.Sprintf("Startup failed: %v", err))
+			return shutdown(1)
 		}
-		if !found {
-			ui.SendLog(program, ui.FormatError(fmt.Sprintf("Replica '%s' not found", replicaName)))
-			return
+	}
+
+	select {
+	case <-sigChan:
+		out.Info("[Sim] Received shutdown signal, stopping all replicas...")
+		return shutdown(0)
+	case err := <-lbErr:
+		out.Error(fmt.Sprintf("Load Balancer failed: %v", err))
+		return shutdown(1)
+	}
+}
+
+// startAdmin serves the admin API in the background if admin_port is set.
+func startAdmin(ctx context.Context, cfg config.Config, r *runner.Runner, balancer *lb.LoadBalancer, out console) {
+	if cfg.AdminPort == 0 {
+		return
+	}
+	go func() {
+		addr := net.JoinHostPort(cfg.AdminAddr, strconv.Itoa(cfg.AdminPort))
+		if err := admin.New(r, balancer).Run(ctx, addr); err != nil {
+			out.Error(fmt.Sprintf("Admin API failed: %v", err))
 		}
-		r.SetIsolatedReplica(replicaName)
-		program.Send(ui.SetFilterMsg(replicaName))
-		ui.SendLog(program, ui.FormatSuccess(fmt.Sprintf("Now showing logs only from: %s", replicaName)))
+	}()
+}
 
-	case "showall":
-		r.SetIsolatedReplica("")
-		program.Send(ui.SetFilterMsg(""))
-		ui.SendLog(program, ui.FormatSuccess("Now showing logs from all replicas"))
+// startControl serves the control socket used by go-sim subcommands in the
+// background. The returned function closes it, which also removes the
+// socket file.
+func startControl(ctx context.Context, cfg config.Config, r *runner.Runner, out console, cancel context.CancelFunc) (stop func()) {
+	ln, err := listenControl(cfg.ControlSocket)
+	if err != nil {
+		log.Printf("[Sim] Control socket disabled: %v", err)
+		return func() {}
+	}
+	log.Printf("[Sim] Control socket listening on %s", cfg.ControlSocket)
+	go serveControl(ctx, ln, r, out, cancel)
+	return func() { ln.Close() }
+}
 
-	case "kill":
-		if len(args) < 1 {
-			ui.SendLog(program, ui.FormatError("Usage: kill <replica-name>"))
-			return
+// startServices builds every service, then starts them tier by tier in
+// dependency order, reporting progress and failures to out. A service that
+// fails is skipped and the rest are still started; the failures are also
+// returned, joined. Once ctx is done no further tier is started.
+func startServices(ctx context.Context, cfg config.Config, r *runner.Runner, out console) error {
+	var errs []error
+	built := make(map[string]bool)
+	for _, svc := range cfg.Services {
+		if err := r.BuildService(svc); err != nil {
+			out.Error(fmt.Sprintf("Failed to build %s: %v", svc.Name, err))
+			errs = append(errs, fmt.Errorf("build %s: %w", svc.Name, err))
+			continue
 		}
-		replicaName := args[0]
-		if err := r.StopReplica(replicaName); err != nil {
-			ui.SendLog(program, ui.FormatError(err.Error()))
-		} else {
-			ui.SendLog(program, ui.FormatSuccess(fmt.Sprintf("Stopped replica: %s", replicaName)))
+		built[svc.Name] = true
+	}
+
+	byName := make(map[string]config.Service, len(cfg.Services))
+	for _, svc := range cfg.Services {
+		byName[svc.Name] = svc
+	}
+	tiers, _ := cfg.StartOrder()
+
+	for _, tier := range tiers {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
 		}
+		for _, name := range tier {
+			svc := byName[name]
+			if !built[svc.Name] {
+				continue
+			}
+			if err := waitForDependencies(ctx, r, svc, byName); err != nil {
+				out.Error(fmt.Sprintf("Not starting %s: %v", svc.Name, err))
+				errs = append(errs, fmt.Errorf("start %s: %w", svc.Name, err))
+				continue
+			}
+			if err := r.StartService(ctx, svc); err != nil {
+				out.Error(fmt.Sprintf("Failed to start %s: %v", svc.Name, err))
+				errs = append(errs, fmt.Errorf("start %s: %w", svc.Name, err))
+			} else {
+				out.Success(fmt.Sprintf("Started service: %s (%d replicas)", svc.Name, svc.Replicas))
+			}
+			if svc.Watch != nil {
+				go r.WatchService(ctx, svc, func(service string, err error) {
+					if err != nil {
+						out.Error(fmt.Sprintf("Hot reload of %s failed, keeping old replicas: %v", service, err))
+					} else {
+						out.Success(fmt.Sprintf("Hot reloaded %s", service))
+					}
+				})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	case "quit", "exit":
-		ui.SendLog(program, "[Sim] Shutting down...")
-		r.ShutdownAll()
-		cancel()
-		program.Quit()
+// waitForServices blocks until every service has a ready replica.
+func waitForServices(ctx context.Context, cfg config.Config, r *runner.Runner) error {
+	for _, svc := range cfg.Services {
+		if svc.Replicas == 0 {
+			continue
+		}
+		if err := r.WaitServiceReady(ctx, svc.Name, svc.ReadinessProbe().Timeout); err != nil {
+			return fmt.Errorf("service %s not ready: %w", svc.Name, err)
+		}
+	}
+	return nil
+}
 
-	default:
-		ui.SendLog(program, ui.FormatError(fmt.Sprintf("Unknown command: %s (type 'help' for available commands)", cmd)))
+// waitForDependencies blocks until every service svc depends on has a ready
+// replica, giving each dependency its own readiness timeout.
+func waitForDependencies(ctx context.Context, r *runner.Runner, svc config.Service, services map[string]config.Service) error {
+	for _, dep := range svc.DependsOn {
+		log.Printf("[Sim] %s is waiting for %s to become ready", svc.Name, dep)
+		if err := r.WaitServiceReady(ctx, dep, services[dep].ReadinessProbe().Timeout); err != nil {
+			return fmt.Errorf("dependency %s not ready: %w", dep, err)
+		}
 	}
+	return nil
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner/runnertest"
+)
+
+// tieredService runs a replica that becomes ready once it logs "ready",
+// after delay.
+func tieredService(name, delay string, dependsOn ...string) config.Service {
+	svc := runnertest.ShellService(name, "sleep "+delay+"; echo ready; exec sleep 60")
+	svc.DependsOn = dependsOn
+	svc.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "^ready$", Timeout: 5 * time.Second}
+	return svc
+}
+
+func runStartup(t *testing.T, services ...config.Service) (*runner.Runner, string, error) {
+	t.Helper()
+	cfg := config.Config{Services: make(map[string]config.Service)}
+	for _, svc := range services {
+		cfg.Services[svc.Name] = svc
+	}
+
+	r := runner.NewRunner()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		r.ShutdownAll()
+	})
+	var buf bytes.Buffer
+	err := startServices(ctx, cfg, r, newStdoutConsole(&buf, false, nil))
+	return r, buf.String(), err
+}
+
+func startedAt(t *testing.T, r *runner.Runner, replica string) time.Time {
+	t.Helper()
+	for _, st := range r.ListReplicas() {
+		if st.Name == replica {
+			return st.StartedAt
+		}
+	}
+	t.Fatalf("replica %s was never started", replica)
+	return time.Time{}
+}
+
+func TestStartServicesInDependencyOrder(t *testing.T) {
+	r, _, err := runStartup(t,
+		tieredService("web", "0", "api"),
+		tieredService("api", "0.2", "db"),
+		tieredService("db", "0.2"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, api, web := startedAt(t, r, "db-1"), startedAt(t, r, "api-1"), startedAt(t, r, "web-1")
+	// Each tier waits for the one before it to log "ready", 200ms in.
+	if api.Sub(db) < 200*time.Millisecond {
+		t.Errorf("api started %s after db, before db was ready", api.Sub(db))
+	}
+	if web.Sub(api) < 200*time.Millisecond {
+		t.Errorf("web started %s after api, before api was ready", web.Sub(api))
+	}
+}
+
+func TestStartServicesSkipsDependentsOfFailedServices(t *testing.T) {
+	never := runnertest.ShellService("db", "exec sleep 60")
+	never.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "^ready$", Timeout: 200 * time.Millisecond}
+
+	r, output, err := runStartup(t, never, tieredService("api", "0", "db"), tieredService("cache", "0"))
+	if err == nil || !strings.Contains(err.Error(), "dependency db not ready") {
+		t.Fatalf("expected api to fail waiting for db, got %v", err)
+	}
+	if !strings.Contains(output, "Not starting api") {
+		t.Errorf("expected the skipped service to be reported, got %q", output)
+	}
+	if r.HasService("api") {
+		t.Error("expected api not to be started")
+	}
+	if !r.HasService("cache") {
+		t.Error("expected services without the failed dependency to start")
+	}
+}
+
+func TestWaitForDependencies(t *testing.T) {
+	r := runner.NewRunner()
+	ctx := context.Background()
+	db := tieredService("db", "0")
+	api := tieredService("api", "0", "db")
+	services := map[string]config.Service{"db": db, "api": api}
+
+	if err := waitForDependencies(ctx, r, api, services); err == nil || !strings.Contains(err.Error(), "not started") {
+		t.Fatalf("expected an error for a dependency that was never started, got %v", err)
+	}
+	if err := waitForDependencies(ctx, r, db, services); err != nil {
+		t.Errorf("a service without dependencies shouldn't wait: %v", err)
+	}
+
+	if err := r.StartService(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	defer r.ShutdownAll()
+	if err := waitForDependencies(ctx, r, api, services); err != nil {
+		t.Errorf("expected db to become ready: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	slow := tieredService("slow", "5")
+	api.DependsOn = []string{"slow"}
+	services["slow"] = slow
+	if err := r.StartService(ctx, slow); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForDependencies(cancelled, r, api, services); err == nil {
+		t.Error("expected waiting to end with the context")
+	}
+}
+
+func TestStartServicesStopsWhenCancelled(t *testing.T) {
+	cfg := config.Config{Services: map[string]config.Service{"db": tieredService("db", "0")}}
+	r := runner.NewRunner()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := startServices(ctx, cfg, r, newStdoutConsole(&bytes.Buffer{}, false, nil))
+	if err == nil {
+		t.Fatal("expected startup to report the cancellation")
+	}
+	if r.HasService("db") {
+		r.ShutdownAll()
+		t.Error("expected nothing to be started once the context is done")
+	}
+}
@@ -1,23 +1,25 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 )
 
 func main() {
 	port := os.Getenv("PORT")
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK on port " + port))
 	})
-	log.Printf("Payment service is starting on port %s", port)
+	logger.Info("Payment service is starting", "port", port)
 	err := http.ListenAndServe(":"+port, mux)
 
 	if err != nil {
-		log.Fatalf("Payment service failed to start: %v", err)
+		logger.Error("Payment service failed to start", "err", err)
+		os.Exit(1)
 	}
 }
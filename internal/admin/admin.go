@@ -0,0 +1,325 @@
+// Package admin serves an HTTP API for controlling a running simulation:
+// listing and signalling replicas, scaling and restarting services, changing
+// load balancer strategies, injecting faults, streaming logs and exposing
+// Prometheus metrics.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/lb"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+type Server struct {
+	runner   *runner.Runner
+	balancer *lb.LoadBalancer
+	mux      *http.ServeMux
+}
+
+func New(r *runner.Runner, balancer *lb.LoadBalancer) *Server {
+	s := &Server{
+		runner:   r,
+		balancer: balancer,
+		mux:      http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /replicas", s.listReplicas)
+	s.mux.HandleFunc("POST /replicas/{name}/kill", s.killReplica)
+	s.mux.HandleFunc("POST /replicas/{name}/stop", s.stopReplica)
+	s.mux.HandleFunc("POST /replicas/{name}/restart", s.restartReplica)
+
+	s.mux.HandleFunc("GET /services", s.listServices)
+	s.mux.HandleFunc("POST /services/{name}/restart", s.restartService)
+	s.mux.HandleFunc("POST /services/{name}/rollout", s.rolloutService)
+	s.mux.HandleFunc("POST /services/{name}/scale", s.scaleService)
+	s.mux.HandleFunc("PUT /services/{name}/strategy", s.setStrategy)
+	s.mux.HandleFunc("PUT /services/{name}/faults", s.setFaults)
+	s.mux.HandleFunc("DELETE /services/{name}/faults", s.clearFaults)
+
+	s.mux.HandleFunc("GET /logs", s.streamLogs)
+	s.mux.HandleFunc("GET /metrics", s.serveMetrics)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Run serves the API on addr until ctx is cancelled.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	server := &http.Server{
+		Addr:        addr,
+		Handler:     s,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	log.Printf("[Admin] Starting admin API on %s", addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+type replicaJSON struct {
+	Name          string    `json:"name"`
+	Service       string    `json:"service"`
+	State         string    `json:"state"`
+	PID           int       `json:"pid,omitempty"`
+	Port          int       `json:"port"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	Restarts      int       `json:"restarts"`
+	LastExitCode  *int      `json:"last_exit_code,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+func (s *Server) listReplicas(w http.ResponseWriter, r *http.Request) {
+	statuses := s.runner.ListReplicas()
+	replicas := make([]replicaJSON, len(statuses))
+	for i, st := range statuses {
+		replicas[i] = replicaJSON{
+			Name:          st.Name,
+			Service:       st.Service,
+			State:         string(st.State),
+			PID:           st.PID,
+			Port:          st.Port,
+			StartedAt:     st.StartedAt,
+			UptimeSeconds: st.Uptime().Seconds(),
+			Restarts:      st.Restarts,
+			LastError:     st.LastError,
+		}
+		if st.Exited {
+			code := st.LastExitCode
+			replicas[i].LastExitCode = &code
+		}
+	}
+	writeJSON(w, http.StatusOK, replicas)
+}
+
+func (s *Server) killReplica(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Signal string `json:"signal"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	sig := syscall.SIGTERM
+	if req.Signal != "" {
+		parsed, err := config.ParseSignal(req.Signal)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		sig = parsed
+	}
+	s.replicaAction(w, r, func(name string) error { return s.runner.KillReplica(name, sig) })
+}
+
+func (s *Server) stopReplica(w http.ResponseWriter, r *http.Request) {
+	s.replicaAction(w, r, s.runner.StopReplica)
+}
+
+func (s *Server) restartReplica(w http.ResponseWriter, r *http.Request) {
+	s.replicaAction(w, r, s.runner.RestartReplica)
+}
+
+func (s *Server) replicaAction(w http.ResponseWriter, r *http.Request, action func(name string) error) {
+	name := r.PathValue("name")
+	if !s.runner.HasReplica(name) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("replica %s not found", name))
+		return
+	}
+	if err := action(name); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type backendJSON struct {
+	Name              string `json:"name"`
+	URL               string `json:"url"`
+	Healthy           bool   `json:"healthy"`
+	ActiveConnections int64  `json:"active_connections"`
+}
+
+type faultsJSON struct {
+	Latency     string  `json:"latency,omitempty"`
+	ErrorRate   float64 `json:"error_rate,omitempty"`
+	ErrorStatus int     `json:"error_status,omitempty"`
+}
+
+type serviceJSON struct {
+	Name     string        `json:"name"`
+	Strategy string        `json:"strategy"`
+	Faults   *faultsJSON   `json:"faults,omitempty"`
+	Backends []backendJSON `json:"backends"`
+}
+
+func (s *Server) listServices(w http.ResponseWriter, r *http.Request) {
+	services := make([]serviceJSON, 0)
+	for _, slb := range s.balancer.Services() {
+		svc := serviceJSON{
+			Name:     slb.Name,
+			Strategy: slb.Strategy().Name(),
+			Backends: make([]backendJSON, 0),
+		}
+		if f := slb.Faults(); f.Active() {
+			svc.Faults = &faultsJSON{ErrorRate: f.ErrorRate, ErrorStatus: f.ErrorStatus}
+			if f.Latency > 0 {
+				svc.Faults.Latency = f.Latency.String()
+			}
+		}
+		for _, b := range slb.BackendList() {
+			svc.Backends = append(svc.Backends, backendJSON{
+				Name:              b.Name,
+				URL:               b.URL.String(),
+				Healthy:           b.Healthy(),
+				ActiveConnections: b.ActiveConnections(),
+			})
+		}
+		services = append(services, svc)
+	}
+	writeJSON(w, http.StatusOK, services)
+}
+
+func (s *Server) restartService(w http.ResponseWriter, r *http.Request) {
+	s.serviceAction(w, r, s.runner.RestartService)
+}
+
+func (s *Server) rolloutService(w http.ResponseWriter, r *http.Request) {
+	s.serviceAction(w, r, s.runner.Rollout)
+}
+
+func (s *Server) scaleService(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Replicas *int `json:"replicas"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Replicas == nil || *req.Replicas < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("replicas must be set to a number >= 0"))
+		return
+	}
+	s.serviceAction(w, r, func(name string) error { return s.runner.Scale(name, *req.Replicas) })
+}
+
+func (s *Server) serviceAction(w http.ResponseWriter, r *http.Request, action func(name string) error) {
+	name := r.PathValue("name")
+	if !s.runner.HasService(name) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("service %s not found", name))
+		return
+	}
+	if err := action(name); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setStrategy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Strategy   string         `json:"strategy"`
+		Weights    map[string]int `json:"weights"`
+		HashHeader string         `json:"hash_header"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	name := r.PathValue("name")
+	if s.balancer.Service(name) == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("service %s not found", name))
+		return
+	}
+	if err := s.balancer.SetStrategy(name, req.Strategy, req.Weights, req.HashHeader); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setFaults(w http.ResponseWriter, r *http.Request) {
+	var req faultsJSON
+	if !readJSON(w, r, &req) {
+		return
+	}
+	slb := s.balancer.Service(r.PathValue("name"))
+	if slb == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("service %s not found", r.PathValue("name")))
+		return
+	}
+
+	var f lb.Faults
+	if req.Latency != "" {
+		d, err := time.ParseDuration(req.Latency)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid latency %q", req.Latency))
+			return
+		}
+		f.Latency = d
+	}
+	if req.ErrorRate < 0 || req.ErrorRate > 1 {
+		writeError(w, http.StatusBadRequest, errors.New("error_rate must be between 0 and 1"))
+		return
+	}
+	if req.ErrorStatus != 0 && (req.ErrorStatus < 400 || req.ErrorStatus > 599) {
+		writeError(w, http.StatusBadRequest, errors.New("error_status must be a 4xx or 5xx status"))
+		return
+	}
+	f.ErrorRate = req.ErrorRate
+	f.ErrorStatus = req.ErrorStatus
+
+	slb.SetFaults(f)
+	log.Printf("[Admin] Faults for %s: latency=%s error_rate=%g", slb.Name, f.Latency, f.ErrorRate)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) clearFaults(w http.ResponseWriter, r *http.Request) {
+	slb := s.balancer.Service(r.PathValue("name"))
+	if slb == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("service %s not found", r.PathValue("name")))
+		return
+	}
+	slb.SetFaults(lb.Faults{})
+	log.Printf("[Admin] Cleared faults for %s", slb.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readJSON decodes an optional JSON request body into v, answering with 400
+// and returning false if it is malformed.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": strings.TrimSpace(err.Error())})
+}
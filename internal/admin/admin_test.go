@@ -0,0 +1,177 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/lb"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/registry"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner/runnertest"
+)
+
+func TestStrategyAndFaults(t *testing.T) {
+	cfg := config.Config{
+		LBPort: 50100,
+		Services: map[string]config.Service{
+			"api": {Name: "api", RoutePrefix: "/api"},
+		},
+	}
+	reg := registry.New()
+	balancer, err := lb.NewLoadBalancer(cfg, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(New(runner.NewRunner(), balancer))
+	defer srv.Close()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := do("PUT", "/services/api/strategy", `{"strategy":"least-connections"}`); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("set strategy: status %d", resp.StatusCode)
+	}
+	if resp := do("PUT", "/services/api/faults", `{"latency":"5ms","error_rate":0.5}`); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("set faults: status %d", resp.StatusCode)
+	}
+	if resp := do("PUT", "/services/api/faults", `{"error_rate":2}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an error rate above 1, got %d", resp.StatusCode)
+	}
+	if resp := do("PUT", "/services/missing/faults", `{}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown service, got %d", resp.StatusCode)
+	}
+
+	resp := do("GET", "/services", "")
+	var services []serviceJSON
+	if err := json.NewDecoder(resp.Body).Decode(&services); err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Strategy != config.StrategyLeastConnections {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+	if f := services[0].Faults; f == nil || f.Latency != "5ms" || f.ErrorRate != 0.5 || f.ErrorStatus != http.StatusServiceUnavailable {
+		t.Errorf("unexpected faults: %+v", f)
+	}
+}
+
+// startTicker runs a service whose single replica, ticker-1, logs an error
+// line and then a debug line every 20ms, and serves the admin API for it.
+func startTicker(t *testing.T) (*runner.Runner, *httptest.Server) {
+	t.Helper()
+	svc := runnertest.ShellService("ticker", `echo '{"level":"error","msg":"boom"}'; i=0; while true; do echo "{\"level\":\"debug\",\"msg\":\"tick $i\"}"; i=$((i+1)); sleep 0.02; done`)
+	svc.RoutePrefix = "/ticker"
+	svc.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "boom"}
+	cfg := config.Config{LBPort: 50101, Services: map[string]config.Service{svc.Name: svc}}
+
+	r := runner.NewRunner()
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := r.StartService(ctx, svc); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.ShutdownAll()
+		cancel()
+	})
+	if err := r.WaitReady(ctx, "ticker-1", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	balancer, err := lb.NewLoadBalancer(cfg, registry.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(New(r, balancer))
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+func request(t *testing.T, srv *httptest.Server, method, path, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func replicaState(t *testing.T, srv *httptest.Server, name string) string {
+	t.Helper()
+	var replicas []replicaJSON
+	if err := json.NewDecoder(request(t, srv, "GET", "/replicas", "").Body).Decode(&replicas); err != nil {
+		t.Fatal(err)
+	}
+	for _, rep := range replicas {
+		if rep.Name == name {
+			return rep.State
+		}
+	}
+	return ""
+}
+
+func TestReplicaEndpoints(t *testing.T) {
+	_, srv := startTicker(t)
+
+	if state := replicaState(t, srv, "ticker-1"); state != string(runner.StateReady) {
+		t.Fatalf("expected ticker-1 to be ready, got %q", state)
+	}
+	for _, action := range []string{"kill", "stop", "restart"} {
+		if resp := request(t, srv, "POST", "/replicas/missing/"+action, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected 404 for an unknown replica, got %d", action, resp.StatusCode)
+		}
+	}
+	if resp := request(t, srv, "POST", "/replicas/ticker-1/kill", `{"signal":"SIGBOGUS"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown signal, got %d", resp.StatusCode)
+	}
+
+	if resp := request(t, srv, "POST", "/replicas/ticker-1/stop", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("stop: status %d", resp.StatusCode)
+	}
+	if state := replicaState(t, srv, "ticker-1"); state != string(runner.StateStopped) {
+		t.Errorf("expected ticker-1 to be stopped, got %q", state)
+	}
+	if resp := request(t, srv, "POST", "/replicas/ticker-1/restart", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("restart: status %d", resp.StatusCode)
+	}
+	if state := replicaState(t, srv, "ticker-1"); state == string(runner.StateStopped) {
+		t.Errorf("expected ticker-1 to run again after a restart")
+	}
+}
+
+func TestScaleValidation(t *testing.T) {
+	_, srv := startTicker(t)
+
+	for body, want := range map[string]int{
+		``:                   http.StatusBadRequest,
+		`{}`:                 http.StatusBadRequest,
+		`{"replicas":-1}`:    http.StatusBadRequest,
+		`{"replicas":"two"}`: http.StatusBadRequest,
+	} {
+		if resp := request(t, srv, "POST", "/services/ticker/scale", body); resp.StatusCode != want {
+			t.Errorf("scale with %q: expected %d, got %d", body, want, resp.StatusCode)
+		}
+	}
+	if resp := request(t, srv, "POST", "/services/missing/scale", `{"replicas":1}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown service, got %d", resp.StatusCode)
+	}
+	if resp := request(t, srv, "POST", "/services/ticker/scale", `{"replicas":0}`); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("scale to 0: status %d", resp.StatusCode)
+	}
+	if state := replicaState(t, srv, "ticker-1"); state != "" {
+		t.Errorf("expected ticker-1 to be gone after scaling to 0, got %q", state)
+	}
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+const defaultLogLimit = 100
+
+type logEntryJSON struct {
+	Seq      uint64            `json:"seq"`
+	Time     time.Time         `json:"time"`
+	Replica  string            `json:"replica"`
+	Stream   string            `json:"stream"`
+	Level    string            `json:"level,omitempty"`
+	Message  string            `json:"msg"`
+	LoggedAt *time.Time        `json:"logged_at,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Raw      string            `json:"raw"`
+}
+
+func toLogEntryJSON(e runner.LogEntry) logEntryJSON {
+	out := logEntryJSON{
+		Seq:     e.Seq,
+		Time:    e.Time,
+		Replica: e.Replica,
+		Stream:  strings.ToLower(string(e.Stream)),
+		Level:   strings.ToLower(e.Level.String()),
+		Message: e.Message,
+		Raw:     e.Raw,
+	}
+	if !e.LoggedAt.IsZero() {
+		out.LoggedAt = &e.LoggedAt
+	}
+	if len(e.Fields) > 0 {
+		out.Fields = make(map[string]string, len(e.Fields))
+		for _, f := range e.Fields {
+			out.Fields[f.Key] = f.Value
+		}
+	}
+	return out
+}
+
+// streamLogs writes stored log lines as newline-delimited JSON. Query
+// parameters: replica, stream (stdout|stderr), level, grep, since (RFC 3339
+// or a duration such as 5m), limit (default 100) and follow, which keeps the
+// response open and streams new lines as they are logged.
+func (s *Server) streamLogs(w http.ResponseWriter, r *http.Request) {
+	q, follow, err := parseLogQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	if !follow {
+		for _, e := range s.runner.GetLogs(q) {
+			enc.Encode(toLogEntryJSON(e))
+		}
+		return
+	}
+
+	// Send the headers now, the first line may take a while.
+	if flusher != nil {
+		flusher.Flush()
+	}
+	s.runner.FollowLogs(r.Context(), q, func(e runner.LogEntry) error {
+		if err := enc.Encode(toLogEntryJSON(e)); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+func parseLogQuery(r *http.Request) (runner.LogQuery, bool, error) {
+	params := r.URL.Query()
+	q := runner.LogQuery{
+		Replica: params.Get("replica"),
+		Limit:   defaultLogLimit,
+	}
+
+	switch strings.ToLower(params.Get("stream")) {
+	case "":
+	case "stdout":
+		q.Stream = runner.StreamStdout
+	case "stderr":
+		q.Stream = runner.StreamStderr
+	default:
+		return q, false, fmt.Errorf("unknown stream %q", params.Get("stream"))
+	}
+
+	if v := params.Get("level"); v != "" {
+		level, ok := runner.ParseLevel(v)
+		if !ok {
+			return q, false, fmt.Errorf("unknown level %q", v)
+		}
+		q.MinLevel = level
+	}
+	if v := params.Get("grep"); v != "" {
+		pattern, err := regexp.Compile(v)
+		if err != nil {
+			return q, false, fmt.Errorf("invalid grep: %w", err)
+		}
+		q.Pattern = pattern
+	}
+	if v := params.Get("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			q.Since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			q.Since = t
+		} else {
+			return q, false, fmt.Errorf("invalid since %q", v)
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, false, fmt.Errorf("invalid limit %q", v)
+		}
+		q.Limit = n
+	}
+
+	follow, _ := strconv.ParseBool(params.Get("follow"))
+	return q, follow, nil
+}
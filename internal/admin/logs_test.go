@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+func getLogs(t *testing.T, srv string, params string) []logEntryJSON {
+	t.Helper()
+	resp, err := http.Get(srv + "/logs?" + params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /logs?%s: status %d", params, resp.StatusCode)
+	}
+	var entries []logEntryJSON
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var e logEntryJSON
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestLogsQuery(t *testing.T) {
+	r, srv := startTicker(t)
+	for len(r.GetLogs(runner.LogQuery{})) < 5 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := getLogs(t, srv.URL, "replica=ticker-1&level=error"); len(got) != 1 || got[0].Message != "boom" || got[0].Level != "error" {
+		t.Errorf("level=error: unexpected entries %+v", got)
+	}
+	if got := getLogs(t, srv.URL, "replica=ticker-1&grep=tick+[0-2]$&limit=0"); len(got) != 3 {
+		t.Errorf("grep: expected 3 entries, got %d", len(got))
+	}
+
+	all := getLogs(t, srv.URL, "limit=0")
+	limited := getLogs(t, srv.URL, "limit=2")
+	if len(limited) != 2 || limited[0].Seq < all[len(all)-2].Seq {
+		t.Errorf("limit=2 should return the newest entries, got %+v", limited)
+	}
+
+	if got := getLogs(t, srv.URL, "since=1h&limit=0"); len(got) < len(all) {
+		t.Errorf("since=1h: expected at least %d entries, got %d", len(all), len(got))
+	}
+	future := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	if got := getLogs(t, srv.URL, "since="+future); len(got) != 0 {
+		t.Errorf("since in the future: expected no entries, got %d", len(got))
+	}
+
+	for _, params := range []string{"level=loud", "stream=both", "grep=(", "since=yesterday", "limit=-1", "limit=ten"} {
+		resp, err := http.Get(srv.URL + "/logs?" + params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", params, resp.StatusCode)
+		}
+	}
+}
+
+// Lines logged between subscribing and reading the history must show up
+// exactly once, so a follower sees every sequence number in order.
+func TestLogsFollow(t *testing.T) {
+	_, srv := startTicker(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/logs?replica=ticker-1&limit=3&follow=true", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var last uint64
+	scanner := bufio.NewScanner(resp.Body)
+	for n := 0; n < 20; n++ {
+		if !scanner.Scan() {
+			t.Fatalf("stream ended after %d lines: %v", n, scanner.Err())
+		}
+		var e logEntryJSON
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if n > 0 && e.Seq != last+1 {
+			t.Fatalf("line %d has seq %d after %d", n, e.Seq, last)
+		}
+		last = e.Seq
+	}
+}
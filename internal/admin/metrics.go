@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/metrics"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+// serveMetrics exposes load balancer and runner metrics in the Prometheus
+// text format.
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mw := metrics.NewWriter(w)
+	s.balancer.WriteMetrics(mw)
+	writeRunnerMetrics(mw, s.runner.ListReplicas())
+}
+
+func writeRunnerMetrics(w *metrics.Writer, replicas []runner.ReplicaStatus) {
+	states := make(map[string]map[runner.ReplicaState]int)
+	for _, st := range replicas {
+		if states[st.Service] == nil {
+			states[st.Service] = make(map[runner.ReplicaState]int)
+		}
+		states[st.Service][st.State]++
+	}
+
+	// Every state is written for every service so that a state emptying out
+	// shows up as 0 rather than a missing series.
+	w.Header("go_sim_replicas", "gauge", "Replicas of each service, by state.")
+	for _, service := range slices.Sorted(maps.Keys(states)) {
+		for _, state := range runner.ReplicaStates {
+			w.Sample("go_sim_replicas", float64(states[service][state]), "service", service, "state", string(state))
+		}
+	}
+
+	w.Header("go_sim_replica_restarts_total", "counter", "Times each replica has been restarted.")
+	for _, st := range replicas {
+		w.Sample("go_sim_replica_restarts_total", float64(st.Restarts), "service", st.Service, "replica", st.Name)
+	}
+}
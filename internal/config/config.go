@@ -4,25 +4,267 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	LBPort   int                `yaml:"lb_port"`
-	Services map[string]Service `yaml:"services"`
+	LBPort        int                `yaml:"lb_port"`
+	AdminPort     int                `yaml:"admin_port"`
+	AdminAddr     string             `yaml:"admin_addr"`
+	ControlSocket string             `yaml:"control_socket"`
+	BuildCacheDir string             `yaml:"build_cache_dir"`
+	LogBuffer     LogBuffer          `yaml:"log_buffer"`
+	LogDir        string             `yaml:"log_dir"`
+	LogMaxSizeMB  int                `yaml:"log_max_size_mb"`
+	LogMaxFiles   int                `yaml:"log_max_files"`
+	Services      map[string]Service `yaml:"services"`
+}
+
+// LogBuffer caps how many log lines are kept in memory. Zero values use the
+// runner's defaults.
+type LogBuffer struct {
+	PerReplica int `yaml:"per_replica"`
+	Total      int `yaml:"total"`
 }
 
 type Service struct {
 	Name        string            `yaml:"name"`
 	Path        string            `yaml:"path"`
+	Command     string            `yaml:"command"`
+	Args        []string          `yaml:"args"`
+	WorkingDir  string            `yaml:"working_dir"`
+	PortMode    string            `yaml:"port_mode"`
 	StartPort   int               `yaml:"start_port"`
 	EndPort     int               `yaml:"end_port"`
 	Replicas    int               `yaml:"replicas"`
 	RoutePrefix string            `yaml:"route_prefix"`
 	Env         map[string]string `yaml:"env"`
+	DependsOn   []string          `yaml:"depends_on"`
+
+	RestartPolicy     string        `yaml:"restart_policy"`
+	MaxRetries        *int          `yaml:"max_retries"`
+	RestartBackoff    time.Duration `yaml:"restart_backoff"`
+	MaxRestartBackoff time.Duration `yaml:"max_restart_backoff"`
+
+	StopSignal  string        `yaml:"stop_signal"`
+	StopTimeout time.Duration `yaml:"stop_timeout"`
+
+	HealthCheck *HealthCheck `yaml:"health_check"`
+	Readiness   *Readiness   `yaml:"readiness"`
+	Rollout     Rollout      `yaml:"rollout"`
+	Watch       *Watch       `yaml:"watch"`
+
+	LBStrategy   string         `yaml:"lb_strategy"`
+	LBHashHeader string         `yaml:"lb_hash_header"`
+	LBWeights    map[string]int `yaml:"lb_weights"`
+}
+
+const (
+	StrategyRoundRobin         = "round-robin"
+	StrategyLeastConnections   = "least-connections"
+	StrategyRandom             = "random"
+	StrategyWeightedRoundRobin = "weighted-round-robin"
+	StrategyIPHash             = "ip-hash"
+	StrategyConsistentHash     = "consistent-hash"
+)
+
+var Strategies = []string{
+	StrategyRoundRobin,
+	StrategyLeastConnections,
+	StrategyRandom,
+	StrategyWeightedRoundRobin,
+	StrategyIPHash,
+	StrategyConsistentHash,
+}
+
+// RetryLimit is how many times in a row a crashed replica is restarted
+// before it is left crash looping: max_retries, or DefaultMaxRetries if it
+// isn't set. 0 means a crashed replica is never restarted.
+func (s Service) RetryLimit() int {
+	if s.MaxRetries == nil {
+		return DefaultMaxRetries
+	}
+	return *s.MaxRetries
+}
+
+type HealthCheck struct {
+	Path               string        `yaml:"path"`
+	Interval           time.Duration `yaml:"interval"`
+	Timeout            time.Duration `yaml:"timeout"`
+	HealthyThreshold   int           `yaml:"healthy_threshold"`
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold"`
+}
+
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeLog  = "log"
+)
+
+// Readiness decides when a freshly started replica may receive traffic:
+// when Path answers with a 2xx/3xx status (http), when the port accepts
+// connections (tcp), or when a log line matches Pattern (log).
+type Readiness struct {
+	Type     string        `yaml:"type"`
+	Path     string        `yaml:"path"`
+	Pattern  string        `yaml:"pattern"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// ReadinessProbe returns the service's readiness probe with defaults filled
+// in. Without one, the health check path is used if there is one and a TCP
+// connect otherwise.
+func (s Service) ReadinessProbe() Readiness {
+	var rd Readiness
+	if s.Readiness != nil {
+		rd = *s.Readiness
+	}
+	if rd.Type == "" {
+		switch {
+		case rd.Pattern != "":
+			rd.Type = ProbeLog
+		case rd.Path != "":
+			rd.Type = ProbeHTTP
+		case s.HealthCheck != nil:
+			rd.Type = ProbeHTTP
+			rd.Path = s.HealthCheck.WithDefaults().Path
+		default:
+			rd.Type = ProbeTCP
+		}
+	}
+	if rd.Type == ProbeHTTP && rd.Path == "" {
+		rd.Path = "/"
+	}
+	if rd.Interval == 0 {
+		rd.Interval = 200 * time.Millisecond
+	}
+	if rd.Timeout == 0 {
+		rd.Timeout = 60 * time.Second
+	}
+	return rd
+}
+
+type Watch struct {
+	Paths    []string      `yaml:"paths"`
+	Debounce time.Duration `yaml:"debounce"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// validateWatchPath checks a watch glob: "**" must make up a whole path
+// segment and every other segment must be a valid filepath.Match pattern.
+func validateWatchPath(path string) error {
+	for _, segment := range strings.Split(filepath.ToSlash(path), "/") {
+		if segment == "**" {
+			continue
+		}
+		if strings.Contains(segment, "**") {
+			return errors.New("** must be a whole path segment, as in dir/**/*.go")
+		}
+		if _, err := filepath.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithDefaults returns a copy of the watch settings with unset fields filled
+// in. Without paths, every .go file below the service's package is watched.
+func (w Watch) WithDefaults(svc Service) Watch {
+	if len(w.Paths) == 0 && svc.Path != "" {
+		dir := svc.Path
+		if strings.HasSuffix(dir, ".go") {
+			dir = filepath.Dir(dir)
+		}
+		w.Paths = []string{filepath.Join(dir, "**", "*.go")}
+	}
+	if w.Debounce == 0 {
+		w.Debounce = 500 * time.Millisecond
+	}
+	if w.Interval == 0 {
+		w.Interval = time.Second
+	}
+	return w
 }
 
+type Rollout struct {
+	MaxSurge       int           `yaml:"max_surge"`
+	MaxUnavailable int           `yaml:"max_unavailable"`
+	ReadyTimeout   time.Duration `yaml:"ready_timeout"`
+}
+
+// WithDefaults returns a copy of the rollout settings with unset fields
+// filled in. A rollout replaces one replica at a time unless told otherwise.
+func (r Rollout) WithDefaults() Rollout {
+	if r.MaxSurge == 0 && r.MaxUnavailable == 0 {
+		r.MaxUnavailable = 1
+	}
+	if r.ReadyTimeout == 0 {
+		r.ReadyTimeout = 30 * time.Second
+	}
+	return r
+}
+
+// WithDefaults returns a copy of the health check with unset fields filled in.
+func (h HealthCheck) WithDefaults() HealthCheck {
+	if h.Path == "" {
+		h.Path = "/health"
+	}
+	if h.Interval == 0 {
+		h.Interval = 5 * time.Second
+	}
+	if h.Timeout == 0 {
+		h.Timeout = 2 * time.Second
+	}
+	if h.HealthyThreshold == 0 {
+		h.HealthyThreshold = 2
+	}
+	if h.UnhealthyThreshold == 0 {
+		h.UnhealthyThreshold = 3
+	}
+	return h
+}
+
+const (
+	PortModeRange   = "range"
+	PortModeDynamic = "dynamic"
+)
+
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+const (
+	DefaultStopSignal  = "SIGTERM"
+	DefaultStopTimeout = 10 * time.Second
+)
+
+// DefaultAdminAddr keeps the admin API, which can kill replicas and read
+// every log, off the network unless admin_addr says otherwise.
+const DefaultAdminAddr = "127.0.0.1"
+
+const (
+	DefaultLogMaxSizeMB = 10
+	DefaultLogMaxFiles  = 5
+)
+
+const (
+	DefaultMaxRetries        = 5
+	DefaultRestartBackoff    = time.Second
+	DefaultMaxRestartBackoff = 30 * time.Second
+)
+
 var DefaultConfig = Config{
 	LBPort: 8079,
 	Services: map[string]Service{
@@ -58,6 +300,8 @@ func LoadConfig(path string) (Config, error) {
 		return Config{}, errors.New("Error parsing config file" + err.Error())
 	}
 
+	config.applyDefaults()
+
 	if err := config.Validate(); err != nil {
 		return Config{}, fmt.Errorf("config validation failed: %w", err)
 	}
@@ -65,20 +309,282 @@ func LoadConfig(path string) (Config, error) {
 	return config, nil
 }
 
+func (c *Config) applyDefaults() {
+	if c.AdminAddr == "" {
+		c.AdminAddr = DefaultAdminAddr
+	}
+	if c.LogMaxSizeMB == 0 {
+		c.LogMaxSizeMB = DefaultLogMaxSizeMB
+	}
+	if c.LogMaxFiles == 0 {
+		c.LogMaxFiles = DefaultLogMaxFiles
+	}
+	for key, svc := range c.Services {
+		if svc.Name == "" {
+			svc.Name = key
+		}
+		if svc.PortMode == "" {
+			svc.PortMode = PortModeRange
+		}
+		if svc.RestartPolicy == "" {
+			svc.RestartPolicy = RestartNever
+		}
+		if svc.RestartBackoff == 0 {
+			svc.RestartBackoff = DefaultRestartBackoff
+		}
+		if svc.MaxRestartBackoff == 0 {
+			svc.MaxRestartBackoff = DefaultMaxRestartBackoff
+		}
+		if svc.StopSignal == "" {
+			svc.StopSignal = DefaultStopSignal
+		}
+		if svc.StopTimeout == 0 {
+			svc.StopTimeout = DefaultStopTimeout
+		}
+		if svc.LBStrategy == "" {
+			svc.LBStrategy = StrategyRoundRobin
+		}
+		svc.Rollout = svc.Rollout.WithDefaults()
+		if svc.Watch != nil {
+			w := svc.Watch.WithDefaults(svc)
+			svc.Watch = &w
+		}
+		if svc.HealthCheck != nil {
+			hc := svc.HealthCheck.WithDefaults()
+			svc.HealthCheck = &hc
+		}
+		c.Services[key] = svc
+	}
+}
+
 func (c Config) Validate() error {
 	if c.LBPort <= 0 {
 		return errors.New("lb_port must be greater than 0")
 	}
+	if c.AdminPort < 0 {
+		return errors.New("admin_port must not be negative")
+	}
+	if c.AdminPort != 0 && c.AdminPort == c.LBPort {
+		return errors.New("admin_port must differ from lb_port")
+	}
+	if c.LogBuffer.PerReplica < 0 || c.LogBuffer.Total < 0 {
+		return errors.New("log_buffer limits must not be negative")
+	}
+	if c.LogDir != "" && c.LogMaxSizeMB < 0 {
+		return errors.New("log_max_size_mb must not be negative")
+	}
+	if c.LogDir != "" && c.LogMaxFiles < 0 {
+		return errors.New("log_max_files must not be negative")
+	}
+	names := make(map[string]bool, len(c.Services))
+	for _, svc := range c.Services {
+		names[svc.Name] = true
+	}
+	for _, svc := range c.Services {
+		for _, dep := range svc.DependsOn {
+			if dep == svc.Name {
+				return fmt.Errorf("service %s: depends on itself", svc.Name)
+			}
+			if !names[dep] {
+				return fmt.Errorf("service %s: depends_on references unknown service %q", svc.Name, dep)
+			}
+		}
+	}
+	if _, err := DependencyTiers(c.Services); err != nil {
+		return err
+	}
+
 	for _, svc := range c.Services {
-		if svc.StartPort <= 0 {
-			return fmt.Errorf("service %s: start_port must be > 0", svc.Name)
+		if svc.Path == "" && svc.Command == "" {
+			return fmt.Errorf("service %s: either path or command must be set", svc.Name)
+		}
+		if svc.Path != "" && svc.Command != "" {
+			return fmt.Errorf("service %s: path and command are mutually exclusive", svc.Name)
+		}
+		switch svc.PortMode {
+		case "", PortModeRange:
+			if svc.StartPort <= 0 {
+				return fmt.Errorf("service %s: start_port must be > 0", svc.Name)
+			}
+			if svc.EndPort <= svc.StartPort {
+				return fmt.Errorf("service %s: end_port must be greater than start_port", svc.Name)
+			}
+			if (svc.EndPort - svc.StartPort + 1) < svc.Replicas {
+				return fmt.Errorf("service %s: port range (%d-%d) is too small for %d replicas", svc.Name, svc.StartPort, svc.EndPort, svc.Replicas)
+			}
+		case PortModeDynamic:
+			if svc.Replicas < 0 {
+				return fmt.Errorf("service %s: replicas must be >= 0", svc.Name)
+			}
+		default:
+			return fmt.Errorf("service %s: unknown port_mode %q (expected %s or %s)", svc.Name, svc.PortMode, PortModeRange, PortModeDynamic)
+		}
+		switch svc.RestartPolicy {
+		case "", RestartNever, RestartOnFailure, RestartAlways:
+		default:
+			return fmt.Errorf("service %s: unknown restart_policy %q (expected %s, %s or %s)", svc.Name, svc.RestartPolicy, RestartNever, RestartOnFailure, RestartAlways)
+		}
+		if svc.MaxRetries != nil && *svc.MaxRetries < 0 {
+			return fmt.Errorf("service %s: max_retries must be >= 0", svc.Name)
 		}
-		if svc.EndPort <= svc.StartPort {
-			return fmt.Errorf("service %s: end_port must be greater than start_port", svc.Name)
+		if svc.RestartBackoff < 0 || svc.MaxRestartBackoff < 0 {
+			return fmt.Errorf("service %s: restart backoff must not be negative", svc.Name)
 		}
-		if (svc.EndPort - svc.StartPort + 1) < svc.Replicas {
-			return fmt.Errorf("service %s: port range (%d-%d) is too small for %d replicas", svc.Name, svc.StartPort, svc.EndPort, svc.Replicas)
+		if svc.StopSignal != "" {
+			if _, err := ParseSignal(svc.StopSignal); err != nil {
+				return fmt.Errorf("service %s: stop_signal: %w", svc.Name, err)
+			}
+		}
+		if svc.StopTimeout < 0 {
+			return fmt.Errorf("service %s: stop_timeout must not be negative", svc.Name)
+		}
+		if svc.LBStrategy != "" && !slices.Contains(Strategies, svc.LBStrategy) {
+			return fmt.Errorf("service %s: unknown lb_strategy %q (expected one of %s)", svc.Name, svc.LBStrategy, strings.Join(Strategies, ", "))
+		}
+		if svc.LBStrategy == StrategyConsistentHash && svc.LBHashHeader == "" {
+			return fmt.Errorf("service %s: lb_hash_header is required for the %s strategy", svc.Name, StrategyConsistentHash)
+		}
+		for replica, w := range svc.LBWeights {
+			if w <= 0 {
+				return fmt.Errorf("service %s: lb_weights for %s must be > 0", svc.Name, replica)
+			}
+		}
+		if svc.Rollout.MaxSurge < 0 || svc.Rollout.MaxUnavailable < 0 || svc.Rollout.ReadyTimeout < 0 {
+			return fmt.Errorf("service %s: rollout settings must not be negative", svc.Name)
+		}
+		if rd := svc.Readiness; rd != nil {
+			switch rd.Type {
+			case "", ProbeHTTP, ProbeTCP:
+			case ProbeLog:
+				if rd.Pattern == "" {
+					return fmt.Errorf("service %s: readiness.pattern is required for log probes", svc.Name)
+				}
+			default:
+				return fmt.Errorf("service %s: unknown readiness type %q (expected %s, %s or %s)", svc.Name, rd.Type, ProbeHTTP, ProbeTCP, ProbeLog)
+			}
+			if rd.Pattern != "" {
+				if _, err := regexp.Compile(rd.Pattern); err != nil {
+					return fmt.Errorf("service %s: readiness.pattern: %w", svc.Name, err)
+				}
+			}
+			if rd.Interval < 0 || rd.Timeout < 0 {
+				return fmt.Errorf("service %s: readiness interval and timeout must not be negative", svc.Name)
+			}
+		}
+		if w := svc.Watch; w != nil {
+			if len(w.Paths) == 0 && svc.Path == "" {
+				return fmt.Errorf("service %s: watch.paths is required for command services", svc.Name)
+			}
+			if w.Debounce < 0 || w.Interval < 0 {
+				return fmt.Errorf("service %s: watch debounce and interval must not be negative", svc.Name)
+			}
+			for _, path := range w.Paths {
+				if err := validateWatchPath(path); err != nil {
+					return fmt.Errorf("service %s: watch.paths: %q: %w", svc.Name, path, err)
+				}
+			}
+		}
+		if hc := svc.HealthCheck; hc != nil {
+			if hc.Interval < 0 || hc.Timeout < 0 {
+				return fmt.Errorf("service %s: health_check interval and timeout must not be negative", svc.Name)
+			}
+			if hc.HealthyThreshold < 0 || hc.UnhealthyThreshold < 0 {
+				return fmt.Errorf("service %s: health_check thresholds must not be negative", svc.Name)
+			}
 		}
 	}
 	return nil
 }
+
+// StartOrder groups service names into tiers that can be started one after
+// the other: every service only depends on services in earlier tiers.
+func (c Config) StartOrder() ([][]string, error) {
+	return DependencyTiers(c.Services)
+}
+
+// DependencyTiers orders services by depends_on. Dependencies on services
+// that aren't in the map are ignored. It fails if the dependencies form a
+// cycle.
+func DependencyTiers(services map[string]Service) ([][]string, error) {
+	pending := make(map[string][]string, len(services))
+	for _, svc := range services {
+		pending[svc.Name] = nil
+	}
+	for _, svc := range services {
+		for _, dep := range svc.DependsOn {
+			if _, ok := pending[dep]; ok {
+				pending[svc.Name] = append(pending[svc.Name], dep)
+			}
+		}
+	}
+
+	var tiers [][]string
+	done := make(map[string]bool, len(pending))
+	for len(done) < len(pending) {
+		var tier []string
+		for name, deps := range pending {
+			if done[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range deps {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				tier = append(tier, name)
+			}
+		}
+
+		if len(tier) == 0 {
+			var cyclic []string
+			for name := range pending {
+				if !done[name] {
+					cyclic = append(cyclic, name)
+				}
+			}
+			sort.Strings(cyclic)
+			return nil, fmt.Errorf("depends_on cycle detected among services: %s", strings.Join(cyclic, ", "))
+		}
+
+		sort.Strings(tier)
+		for _, name := range tier {
+			done[name] = true
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+// ParseSignal accepts a signal name with or without the SIG prefix
+// ("SIGTERM", "term") or its number ("15").
+func ParseSignal(name string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	if sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", name)
+}
+
+func SignalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	return sig.String()
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoadConfigInvalidPortRange(t *testing.T) {
+	_, err := LoadConfig("testdata/invalid_simulation.yaml")
+	if err == nil || !strings.Contains(err.Error(), "end_port must be greater than start_port") {
+		t.Fatalf("expected port range error, got %v", err)
+	}
+}
+
+func TestLoadConfigDependencyCycle(t *testing.T) {
+	_, err := LoadConfig("testdata/cyclic_simulation.yaml")
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+}
+
+func TestStartOrder(t *testing.T) {
+	svc := func(name string, deps ...string) Service {
+		return Service{Name: name, Path: "./main.go", StartPort: 9000, EndPort: 9001, Replicas: 1, DependsOn: deps}
+	}
+	cfg := Config{
+		LBPort: 8080,
+		Services: map[string]Service{
+			"gateway": svc("gateway", "auth", "payment"),
+			"payment": svc("payment", "auth", "db"),
+			"auth":    svc("auth", "db"),
+			"db":      svc("db"),
+			"mail":    svc("mail"),
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	tiers, err := cfg.StartOrder()
+	if err != nil {
+		t.Fatalf("StartOrder failed: %v", err)
+	}
+	want := [][]string{{"db", "mail"}, {"auth"}, {"payment"}, {"gateway"}}
+	if !reflect.DeepEqual(tiers, want) {
+		t.Fatalf("expected %v, got %v", want, tiers)
+	}
+}
+
+func TestValidateUnknownDependency(t *testing.T) {
+	cfg := Config{
+		LBPort: 8080,
+		Services: map[string]Service{
+			"auth": {Name: "auth", Path: "./main.go", StartPort: 9000, EndPort: 9001, Replicas: 1, DependsOn: []string{"db"}},
+		},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown service") {
+		t.Fatalf("expected unknown dependency error, got %v", err)
+	}
+}
+
+func TestMaxRetriesZeroIsKept(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+services:
+  none: {max_retries: 0}
+  unset: {}
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.applyDefaults()
+	if got := cfg.Services["none"].RetryLimit(); got != 0 {
+		t.Errorf("max_retries: 0 gave a retry limit of %d", got)
+	}
+	if got := cfg.Services["unset"].RetryLimit(); got != DefaultMaxRetries {
+		t.Errorf("unset max_retries gave a retry limit of %d, want %d", got, DefaultMaxRetries)
+	}
+}
+
+func TestValidateWatchPaths(t *testing.T) {
+	for path, valid := range map[string]bool{
+		"internal/**/handlers/*.go": true,
+		"**/*.go":                   true,
+		"a/**/b/**/*.go":            true,
+		"src/**.go":                 false,
+		"src/a**/*.go":              false,
+		"src/[a-/*.go":              false,
+	} {
+		cfg := Config{
+			LBPort: 8080,
+			Services: map[string]Service{
+				"auth": {Name: "auth", Path: "./main.go", StartPort: 9000, EndPort: 9001, Replicas: 1, Watch: &Watch{Paths: []string{path}}},
+			},
+		}
+		err := cfg.Validate()
+		if valid && err != nil {
+			t.Errorf("%s: unexpected error %v", path, err)
+		}
+		if !valid && (err == nil || !strings.Contains(err.Error(), "watch.paths")) {
+			t.Errorf("%s: expected a watch.paths error, got %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+// ReplicasMsg refreshes the replica sidebar.
+type ReplicasMsg []runner.ReplicaStatus
+
+const maxSidebarWidth = 56
+
+var (
+	sidebarStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderRight(true).
+			BorderForeground(lipgloss.Color("#6C5CE7"))
+
+	sidebarTitleStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#6C5CE7")).
+				Bold(true)
+
+	serviceRowStyle = lipgloss.NewStyle().Bold(true)
+
+	selectedRowStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FFFDF5")).
+				Background(lipgloss.Color("#6C5CE7"))
+
+	buttonStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#A0A0A0"))
+)
+
+// sidebarAction is a command the sidebar can run on the selected replica,
+// bound to a key and to a clickable button.
+type sidebarAction struct {
+	label string
+	key   string
+	cmd   string
+}
+
+var sidebarActions = []sidebarAction{
+	{label: "isolate", key: "enter", cmd: "isolate"},
+	{label: "restart", key: "r", cmd: "restart"},
+	{label: "kill", key: "x", cmd: "kill"},
+	{label: "kill -9", key: "X", cmd: "kill -9"},
+}
+
+// sidebarRow is a service heading when replica is nil, otherwise a replica.
+type sidebarRow struct {
+	service string
+	ready   int
+	total   int
+	replica *runner.ReplicaStatus
+}
+
+type sidebar struct {
+	rows     []sidebarRow
+	selected string
+	offset   int
+	focused  bool
+	width    int
+	height   int
+}
+
+func (s *sidebar) setReplicas(replicas []runner.ReplicaStatus) {
+	s.rows = s.rows[:0]
+	heading := -1
+	for i := range replicas {
+		st := &replicas[i]
+		if heading < 0 || s.rows[heading].service != st.Service {
+			s.rows = append(s.rows, sidebarRow{service: st.Service})
+			heading = len(s.rows) - 1
+		}
+		s.rows[heading].total++
+		if st.State == runner.StateReady {
+			s.rows[heading].ready++
+		}
+		s.rows = append(s.rows, sidebarRow{service: st.Service, replica: st})
+	}
+
+	if s.selectedIndex() < 0 {
+		s.selected = ""
+		s.move(1)
+	}
+	s.ensureVisible()
+}
+
+func (s *sidebar) selectedIndex() int {
+	for i, row := range s.rows {
+		if row.replica != nil && row.replica.Name == s.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *sidebar) selectedReplica() string {
+	if s.selectedIndex() < 0 {
+		return ""
+	}
+	return s.selected
+}
+
+// move selects the replica delta replica rows away from the current one,
+// skipping service headings and stopping at either end.
+func (s *sidebar) move(delta int) {
+	i := s.selectedIndex()
+	step := 1
+	if delta < 0 {
+		step, delta = -1, -delta
+	}
+	for j := i + step; j >= 0 && j < len(s.rows) && delta > 0; j += step {
+		if s.rows[j].replica != nil {
+			s.selected = s.rows[j].replica.Name
+			delta--
+		}
+	}
+	s.ensureVisible()
+}
+
+// visibleRows is how many rows fit between the title and the action bar.
+func (s *sidebar) visibleRows() int {
+	return max(1, s.height-2)
+}
+
+func (s *sidebar) ensureVisible() {
+	i := s.selectedIndex()
+	if i < 0 {
+		return
+	}
+	// Keep the service heading in view when its first replica is selected.
+	top := i
+	if i > 0 && s.rows[i-1].replica == nil {
+		top = i - 1
+	}
+	if top < s.offset {
+		s.offset = top
+	}
+	if i >= s.offset+s.visibleRows() {
+		s.offset = i - s.visibleRows() + 1
+	}
+}
+
+func (s *sidebar) scroll(delta int) {
+	s.offset = max(0, min(s.offset+delta, len(s.rows)-s.visibleRows()))
+}
+
+// click handles a left click at x, y relative to the sidebar's top left
+// corner and returns the command to run, if any.
+func (s *sidebar) click(x, y int) string {
+	switch {
+	case y == 0:
+		return ""
+	case y == s.height-1:
+		pos := 0
+		for _, a := range sidebarActions {
+			w := len(a.label) + 2
+			if x >= pos && x < pos+w {
+				return s.command(a)
+			}
+			pos += w + 1
+		}
+		return ""
+	}
+	i := s.offset + y - 1
+	if i < len(s.rows) && s.rows[i].replica != nil {
+		s.selected = s.rows[i].replica.Name
+	}
+	return ""
+}
+
+// command returns the command line for running a on the selected replica.
+func (s *sidebar) command(a sidebarAction) string {
+	name := s.selectedReplica()
+	if name == "" {
+		return ""
+	}
+	return a.cmd + " " + name
+}
+
+func (s *sidebar) view() string {
+	width := s.width
+	var lines []string
+
+	title := "Replicas"
+	if s.focused {
+		title += " (↑/↓ select, Tab back)"
+	}
+	lines = append(lines, sidebarTitleStyle.Render(truncate(title, width)))
+
+	end := min(len(s.rows), s.offset+s.visibleRows())
+	for _, row := range s.rows[s.offset:end] {
+		lines = append(lines, s.rowView(row, width))
+	}
+	for len(lines) < s.height-1 {
+		lines = append(lines, "")
+	}
+
+	buttons := make([]string, len(sidebarActions))
+	for i, a := range sidebarActions {
+		buttons[i] = "[" + a.label + "]"
+	}
+	lines = append(lines, buttonStyle.Render(truncate(strings.Join(buttons, " "), width)))
+
+	return sidebarStyle.Width(width).Height(s.height).Render(strings.Join(lines, "\n"))
+}
+
+func (s *sidebar) rowView(row sidebarRow, width int) string {
+	if row.replica == nil {
+		return serviceRowStyle.Render(truncate(fmt.Sprintf("%s  %d/%d ready", row.service, row.ready, row.total), width))
+	}
+
+	st := row.replica
+	uptime := "-"
+	if d := st.Uptime(); d > 0 {
+		uptime = d.Round(time.Second).String()
+	}
+	health := " "
+	switch st.State {
+	case runner.StateReady:
+		health = "✓"
+	case runner.StateUnhealthy:
+		health = "✗"
+	}
+	text := truncate(fmt.Sprintf("%s %-16s %-12s :%-5d ↻%-2d %s", health, st.Name, st.State, st.Port, st.Restarts, uptime), width)
+
+	if st.Name == s.selected {
+		return selectedRowStyle.Render(fmt.Sprintf("%-*s", width, text))
+	}
+	if style, ok := stateStyles[st.State]; ok {
+		return style.Render(text)
+	}
+	return text
+}
+
+// handleKey handles a key press while the sidebar has focus and returns the
+// command to run, if any.
+func (s *sidebar) handleKey(msg tea.KeyMsg, isolated string) string {
+	switch msg.String() {
+	case "up", "k":
+		s.move(-1)
+	case "down", "j":
+		s.move(1)
+	case "pgup":
+		s.move(-s.visibleRows())
+	case "pgdown":
+		s.move(s.visibleRows())
+	case "enter", "i":
+		// Isolating the replica that is already isolated shows all again.
+		if name := s.selectedReplica(); name != "" && name == isolated {
+			return "showall"
+		}
+		return s.command(sidebarActions[0])
+	default:
+		for _, a := range sidebarActions {
+			if msg.String() == a.key {
+				return s.command(a)
+			}
+		}
+	}
+	return ""
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string(r[:max(0, width)])
+	}
+	return string(r[:width-1]) + "…"
+}
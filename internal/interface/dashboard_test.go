@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func snapshot() []runner.ReplicaStatus {
+	started := time.Now().Add(-90 * time.Second)
+	return []runner.ReplicaStatus{
+		{Name: "api-1", Service: "api", State: runner.StateReady, Port: 8081, StartedAt: started, Restarts: 2},
+		{Name: "api-2", Service: "api", State: runner.StateUnhealthy, Port: 8082, StartedAt: started},
+		{Name: "worker-1", Service: "worker", State: runner.StateStopped, Port: 9001},
+	}
+}
+
+// sidebarLines renders s and returns its lines without styling or the
+// right border.
+func sidebarLines(s *sidebar) []string {
+	lines := strings.Split(ansiEscape.ReplaceAllString(s.view(), ""), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(strings.TrimSuffix(strings.TrimRight(line, " "), "│"), " ")
+	}
+	return lines
+}
+
+func TestSidebarView(t *testing.T) {
+	s := &sidebar{width: 56, height: 8}
+	s.setReplicas(snapshot())
+
+	want := []string{
+		"Replicas",
+		"api  1/2 ready",
+		"✓ api-1            ready        :8081  ↻2  1m30s",
+		"✗ api-2            unhealthy    :8082  ↻0  1m30s",
+		"worker  0/1 ready",
+		"  worker-1         stopped      :9001  ↻0  -",
+		"",
+		"[isolate] [restart] [kill] [kill -9]",
+	}
+	got := sidebarLines(s)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d lines, got %d:\n%s", len(want), len(got), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+	if s.selectedReplica() != "api-1" {
+		t.Errorf("expected the first replica to be selected, got %q", s.selectedReplica())
+	}
+}
+
+func TestSidebarTruncatesAndScrolls(t *testing.T) {
+	s := &sidebar{width: 20, height: 4}
+	s.setReplicas(snapshot())
+	s.move(2)
+
+	got := sidebarLines(s)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 lines, got %q", got)
+	}
+	// Two rows fit, so selecting worker-1 scrolls its heading into view.
+	if got[1] != "worker  0/1 ready" || !strings.HasPrefix(got[2], "  worker-1") {
+		t.Errorf("expected worker's rows in view, got %q", got[1:3])
+	}
+	for _, line := range got {
+		if n := len([]rune(line)); n > s.width {
+			t.Errorf("line %q is %d wide, more than %d", line, n, s.width)
+		}
+	}
+	if !strings.HasSuffix(got[3], "…") {
+		t.Errorf("expected the action bar to be truncated, got %q", got[3])
+	}
+}
+
+func TestSidebarSelection(t *testing.T) {
+	s := &sidebar{width: 56, height: 8}
+	s.setReplicas(snapshot())
+
+	// Row 3 is api-2, below the title and the api heading; the action bar
+	// is the last line.
+	s.click(0, 3)
+	if got := s.click(10, s.height-1); got != "restart api-2" {
+		t.Errorf("expected the restart button to restart api-2, got %q", got)
+	}
+	if got := s.handleKey(tea.KeyMsg{Type: tea.KeyEnter}, ""); got != "isolate api-2" {
+		t.Errorf("expected enter to isolate api-2, got %q", got)
+	}
+	if got := s.handleKey(tea.KeyMsg{Type: tea.KeyEnter}, "api-2"); got != "showall" {
+		t.Errorf("expected enter on the isolated replica to show all, got %q", got)
+	}
+
+	// A new snapshot without the selected replica moves the selection.
+	s.setReplicas(snapshot()[2:])
+	if s.selectedReplica() != "worker-1" {
+		t.Errorf("expected worker-1 to be selected, got %q", s.selectedReplica())
+	}
+}
@@ -2,21 +2,35 @@ package ui
 
 import (
 	"fmt"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
 )
 
 type LogMsg string
 type StatusMsg string
-type SetFilterMsg string
+
+// LogEntryMsg is a replica log line; the model formats it itself so that
+// search matches can be highlighted.
+type LogEntryMsg runner.LogEntry
+
+// ReplayMsg replaces the log view with Entries and sets the log filter shown
+// in the header and used for highlighting.
+type ReplayMsg struct {
+	Filter  runner.LogQuery
+	Entries []runner.LogEntry
+}
 
 type Program = tea.Program
 
+type Msg = tea.Msg
+
 var (
 	titleStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#FFFDF5")).
@@ -43,6 +57,15 @@ var (
 			Foreground(lipgloss.Color("#6C5CE7"))
 )
 
+var levelStyles = map[runner.LogLevel]lipgloss.Style{
+	runner.LevelDebug: lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")),
+	runner.LevelInfo:  lipgloss.NewStyle().Foreground(lipgloss.Color("#74B9FF")),
+	runner.LevelWarn:  lipgloss.NewStyle().Foreground(lipgloss.Color("#F1C40F")),
+	runner.LevelError: lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B")).Bold(true),
+}
+
+const commandPlaceholder = "Type a command (help for list)..."
+
 type Model struct {
 	viewport       viewport.Model
 	textInput      textinput.Model
@@ -50,6 +73,12 @@ type Model struct {
 	content        string
 	statusMessage  string
 	isolatedFilter string
+	minLevel       runner.LogLevel
+	search         search
+	lineCount      int
+	system         []systemLine
+	lastSeq        uint64
+	sidebar        sidebar
 	ready          bool
 	width          int
 	height         int
@@ -57,7 +86,7 @@ type Model struct {
 
 func NewModel(cmdChan chan<- string) Model {
 	ti := textinput.New()
-	ti.Placeholder = "Type a command (help for list)..."
+	ti.Placeholder = commandPlaceholder
 	ti.Focus()
 	ti.CharLimit = 256
 	ti.Width = 50
@@ -87,36 +116,102 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		footerHeight := lipgloss.Height(m.footerView())
 		verticalMarginHeight := headerHeight + footerHeight + 1
 
+		// The sidebar takes up to two fifths of the width plus its border;
+		// the log pane gets the rest.
+		m.sidebar.width = min(maxSidebarWidth, msg.Width*2/5)
+		m.sidebar.height = msg.Height - verticalMarginHeight
+		m.sidebar.ensureVisible()
+		logWidth := msg.Width - m.sidebar.width - 1
+
 		if !m.ready {
-			m.viewport = viewport.New(msg.Width, msg.Height-verticalMarginHeight)
+			m.viewport = viewport.New(logWidth, msg.Height-verticalMarginHeight)
 			m.viewport.YPosition = headerHeight
 			m.viewport.SetContent(m.content)
 			m.ready = true
 		} else {
-			m.viewport.Width = msg.Width
+			m.viewport.Width = logWidth
 			m.viewport.Height = msg.Height - verticalMarginHeight
 		}
 
+	case ReplicasMsg:
+		m.sidebar.setReplicas(msg)
+
+	case tea.MouseMsg:
+		bodyTop := lipgloss.Height(m.headerView())
+		if msg.X <= m.sidebar.width && msg.Y >= bodyTop && msg.Y < bodyTop+m.sidebar.height {
+			switch {
+			case msg.Button == tea.MouseButtonWheelUp:
+				m.sidebar.scroll(-3)
+			case msg.Button == tea.MouseButtonWheelDown:
+				m.sidebar.scroll(3)
+			case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+				m.runCommand(m.sidebar.click(msg.X, msg.Y-bodyTop))
+			}
+			return m, nil
+		}
+
 	case LogMsg:
-		m.content += string(msg) + "\n"
+		m.appendSystem(string(msg))
+		m.viewport.SetContent(m.content)
+		m.viewport.GotoBottom()
+
+	case LogEntryMsg:
+		m.appendEntry(runner.LogEntry(msg))
 		m.viewport.SetContent(m.content)
 		m.viewport.GotoBottom()
 
 	case StatusMsg:
 		m.statusMessage = string(msg)
 
-	case SetFilterMsg:
-		m.isolatedFilter = string(msg)
+	case ReplayMsg:
+		m.isolatedFilter = msg.Filter.Replica
+		m.minLevel = msg.Filter.MinLevel
+		m.search.reset(msg.Filter.Pattern)
+		m.redraw(msg.Entries)
+		m.viewport.SetContent(m.content)
+		m.viewport.GotoBottom()
 
 	case tea.KeyMsg:
+		if msg.Type == tea.KeyTab {
+			m.toggleSidebarFocus()
+			return m, nil
+		}
+		if m.sidebar.focused && msg.Type != tea.KeyCtrlC {
+			if msg.Type == tea.KeyEsc {
+				m.toggleSidebarFocus()
+			} else {
+				m.runCommand(m.sidebar.handleKey(msg, m.isolatedFilter))
+			}
+			return m, nil
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC:
 			go func() { m.cmdChan <- "quit" }()
 			return m, tea.Quit
 		case tea.KeyEsc:
+			if m.search.prompting {
+				m.endSearchPrompt()
+				return m, nil
+			}
 			return m, tea.Quit
+		case tea.KeyCtrlN:
+			m.gotoMatch(1)
+			return m, nil
+		case tea.KeyCtrlP:
+			m.gotoMatch(-1)
+			return m, nil
+		case tea.KeyRunes:
+			if string(msg.Runes) == "/" && !m.search.prompting && m.textInput.Value() == "" {
+				m.startSearchPrompt()
+				return m, nil
+			}
 		case tea.KeyEnter:
 			cmd := strings.TrimSpace(m.textInput.Value())
+			if m.search.prompting {
+				cmd = "grep " + cmd
+				m.endSearchPrompt()
+			}
 			if cmd != "" {
 				go func() { m.cmdChan <- cmd }()
 				m.textInput.SetValue("")
@@ -134,7 +229,26 @@ func (m Model) View() string {
 	if !m.ready {
 		return "\n  Initializing..."
 	}
-	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.viewport.View(), m.footerView())
+	body := lipgloss.JoinHorizontal(lipgloss.Top, m.sidebar.view(), m.viewport.View())
+	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), body, m.footerView())
+}
+
+// runCommand sends cmd as if it had been typed into the input.
+func (m *Model) runCommand(cmd string) {
+	if cmd == "" {
+		return
+	}
+	cmds := m.cmdChan
+	go func() { cmds <- cmd }()
+}
+
+func (m *Model) toggleSidebarFocus() {
+	m.sidebar.focused = !m.sidebar.focused
+	if m.sidebar.focused {
+		m.textInput.Blur()
+	} else {
+		m.textInput.Focus()
+	}
 }
 
 func (m Model) headerView() string {
@@ -146,6 +260,12 @@ func (m Model) headerView() string {
 	} else {
 		status = statusStyle.Render("Showing: all")
 	}
+	if m.minLevel != runner.LevelUnknown {
+		status += statusStyle.Render(fmt.Sprintf("Level: %s+", m.minLevel))
+	}
+	if m.search.pattern != nil {
+		status += statusStyle.Render(fmt.Sprintf("Grep: %s %s", m.search.pattern, m.search.position()))
+	}
 
 	titleWidth := lipgloss.Width(title)
 	statusWidth := lipgloss.Width(status)
@@ -162,15 +282,11 @@ func (m Model) footerView() string {
 	}
 
 	inputLine := m.textInput.View()
-	hint := helpStyle.Render(" (Ctrl+C to quit)")
+	hint := helpStyle.Render(" (Tab replicas, / search, Ctrl+N/P next/prev match, Ctrl+C quit)")
 
 	return statusLine + inputLine + hint
 }
 
-func (m *Model) SetIsolatedFilter(name string) {
-	m.isolatedFilter = name
-}
-
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -184,24 +300,92 @@ func HelpText() string {
 │                 Available Commands               │
 ├─────────────────────────────────────────────────┤
 │  help              Show this help message        │
-│  list              List all running replicas     │
+│  list              List all replicas             │
 │  isolate <name>    Show logs from one replica    │
 │  showall           Show logs from all replicas   │
-│  kill <name>       Stop a specific replica       │
+│  level <min>       Hide lines below a log level  │
+│  grep <regex>      Show only matching lines      │
+│  grep              Clear the search              │
+│  stop <name>       Gracefully stop a replica     │
+│  kill <name>       Send SIGTERM to a replica     │
+│  kill -9 <name>    Hard kill a replica (SIGKILL) │
+│  restart <name>    Restart a replica or service  │
+│  rollout <svc>     Rebuild and roll a service    │
+│  scale <svc> <n>   Scale a service to n replicas │
 │  quit              Shutdown and exit             │
 └─────────────────────────────────────────────────┘`
 }
 
-func FormatReplicaList(replicas []string) string {
+var stateStyles = map[runner.ReplicaState]lipgloss.Style{
+	runner.StateStarting:  lipgloss.NewStyle().Foreground(lipgloss.Color("#F1C40F")),
+	runner.StateReady:     lipgloss.NewStyle().Foreground(lipgloss.Color("#2ECC71")),
+	runner.StateUnhealthy: lipgloss.NewStyle().Foreground(lipgloss.Color("#E67E22")),
+	runner.StateBackoff:   lipgloss.NewStyle().Foreground(lipgloss.Color("#E67E22")),
+	runner.StateCrashLoop: lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B")).Bold(true),
+	runner.StateStopping:  lipgloss.NewStyle().Foreground(lipgloss.Color("#A0A0A0")),
+	runner.StateStopped:   lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")),
+	runner.StateExited:    lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")),
+}
+
+func FormatReplicaList(replicas []runner.ReplicaStatus) string {
 	if len(replicas) == 0 {
-		return "No replicas running."
+		return "No replicas."
 	}
 
-	sort.Strings(replicas)
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Running replicas (%d):\n", len(replicas)))
+	header := []string{"NAME", "SERVICE", "STATE", "PID", "PORT", "UPTIME", "RESTARTS", "EXIT", "LAST ERROR"}
+	rows := make([][]string, 0, len(replicas))
 	for _, r := range replicas {
-		sb.WriteString(fmt.Sprintf("  • %s\n", r))
+		pid, uptime, exit := "-", "-", "-"
+		if r.PID != 0 {
+			pid = fmt.Sprint(r.PID)
+		}
+		if d := r.Uptime(); d > 0 {
+			uptime = d.Round(time.Second).String()
+		}
+		if r.Exited {
+			exit = fmt.Sprint(r.LastExitCode)
+		}
+		lastErr := r.LastError
+		if lastErr == "" {
+			lastErr = "-"
+		}
+		rows = append(rows, []string{r.Name, r.Service, string(r.State), pid, fmt.Sprint(r.Port), uptime, fmt.Sprint(r.Restarts), exit, lastErr})
+	}
+
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	// Pad before styling so ANSI codes don't throw off the alignment.
+	formatRow := func(row []string, style func(col int, cell string) string) string {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			padded := cell
+			if i < len(row)-1 {
+				padded = fmt.Sprintf("%-*s", widths[i], cell)
+			}
+			cells[i] = style(i, padded)
+		}
+		return "  " + strings.Join(cells, "  ") + "\n"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Replicas (%d):\n", len(replicas)))
+	sb.WriteString(formatRow(header, func(_ int, cell string) string { return helpStyle.Render(cell) }))
+	for i, row := range rows {
+		state := replicas[i].State
+		sb.WriteString(formatRow(row, func(col int, cell string) string {
+			if style, ok := stateStyles[state]; ok && col == 2 {
+				return style.Render(cell)
+			}
+			return cell
+		}))
 	}
 	return sb.String()
 }
@@ -214,26 +398,25 @@ func FormatSuccess(msg string) string {
 	return successStyle.Render("✓ " + msg)
 }
 
-func Setup() (logChan chan<- string, cmdChan <-chan string, program *tea.Program) {
-	logs := make(chan string, 100)
+// Setup creates the program. Log lines, replica log entries and replays sent
+// on msgs reach it in order; msgs is buffered so that senders only wait when
+// the TUI falls far behind.
+func Setup() (msgs chan<- Msg, cmdChan <-chan string, program *tea.Program) {
+	queue := make(chan Msg, 4096)
 	cmds := make(chan string, 100)
 
 	m := NewModel(cmds)
 	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 
 	go func() {
-		for msg := range logs {
-			p.Send(LogMsg(msg))
+		for msg := range queue {
+			p.Send(msg)
 		}
 	}()
-	
-	return logs, cmds, p
-}
 
-func SendStatus(p *tea.Program, msg string) {
-	p.Send(StatusMsg(msg))
+	return queue, cmds, p
 }
 
-func SendLog(p *tea.Program, msg string) {
-	p.Send(LogMsg(msg))
+func SendReplicas(p *tea.Program, replicas []runner.ReplicaStatus) {
+	p.Send(ReplicasMsg(replicas))
 }
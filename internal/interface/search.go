@@ -0,0 +1,167 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner"
+)
+
+var matchStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#1E1E1E")).
+	Background(lipgloss.Color("#F1C40F"))
+
+// search is the state of the current grep: the pattern being highlighted,
+// the view lines holding a match and the match last jumped to.
+type search struct {
+	pattern   *regexp.Regexp
+	lines     []int
+	current   int
+	prompting bool
+}
+
+func (s *search) reset(pattern *regexp.Regexp) {
+	s.pattern = pattern
+	s.lines = s.lines[:0]
+	s.current = -1
+}
+
+func (s search) position() string {
+	if len(s.lines) == 0 {
+		return "(no matches)"
+	}
+	if s.current < 0 {
+		return fmt.Sprintf("(%d matches)", len(s.lines))
+	}
+	return fmt.Sprintf("(%d/%d)", s.current+1, len(s.lines))
+}
+
+func (m *Model) appendLine(text string) {
+	m.content += text + "\n"
+	m.lineCount += strings.Count(text, "\n") + 1
+}
+
+func (m *Model) appendEntry(e runner.LogEntry) {
+	if e.Seq > m.lastSeq {
+		m.lastSeq = e.Seq
+	}
+	if m.search.pattern != nil && m.search.pattern.MatchString(e.Line()) {
+		m.search.lines = append(m.search.lines, m.lineCount)
+	}
+	m.appendLine(highlightEntry(e, m.search.pattern))
+}
+
+// maxSystemLines caps how many orchestrator lines are kept for redraws.
+const maxSystemLines = 1000
+
+// systemLine is an orchestrator line ([Sim], [LB], command output) in the log
+// view, kept so that redraws don't lose it. after is the sequence number of
+// the newest replica line shown before it.
+type systemLine struct {
+	after uint64
+	text  string
+}
+
+func (m *Model) appendSystem(text string) {
+	if len(m.system) == maxSystemLines {
+		m.system = append(m.system[:0], m.system[1:]...)
+	}
+	m.system = append(m.system, systemLine{after: m.lastSeq, text: text})
+	m.appendLine(text)
+}
+
+// redraw replaces the log view with entries, putting the orchestrator lines
+// back where they were relative to the replica lines.
+func (m *Model) redraw(entries []runner.LogEntry) {
+	m.content = ""
+	m.lineCount = 0
+	sys := m.system
+	for _, e := range entries {
+		for len(sys) > 0 && sys[0].after < e.Seq {
+			m.appendLine(sys[0].text)
+			sys = sys[1:]
+		}
+		m.appendEntry(e)
+	}
+	for _, l := range sys {
+		m.appendLine(l.text)
+	}
+}
+
+// gotoMatch scrolls the log view to the next (dir > 0) or previous match,
+// wrapping around at either end. Before the first jump the previous match is
+// the newest one.
+func (m *Model) gotoMatch(dir int) {
+	n := len(m.search.lines)
+	if n == 0 {
+		return
+	}
+	switch {
+	case m.search.current < 0 && dir < 0:
+		m.search.current = n - 1
+	case m.search.current < 0:
+		m.search.current = 0
+	default:
+		m.search.current = (m.search.current + dir + n) % n
+	}
+	m.viewport.SetYOffset(m.search.lines[m.search.current] - m.viewport.Height/2)
+}
+
+func (m *Model) startSearchPrompt() {
+	m.search.prompting = true
+	m.textInput.Prompt = "/ "
+	m.textInput.Placeholder = "Regex to search for, empty to clear..."
+}
+
+func (m *Model) endSearchPrompt() {
+	m.search.prompting = false
+	m.textInput.Prompt = "> "
+	m.textInput.Placeholder = commandPlaceholder
+	m.textInput.SetValue("")
+}
+
+// highlightEntry renders a replica log line, coloured by its level when it
+// has one, and marks every match of pattern. Matching runs on the plain line
+// so styling can't split a match.
+func highlightEntry(e runner.LogEntry, pattern *regexp.Regexp) string {
+	line := e.Line()
+	textStart := len(e.Prefix()) + 1
+	textStyle, styled := levelStyles[e.Level]
+
+	var sb strings.Builder
+	render := func(from, to int, matched bool) {
+		for from < to {
+			end := to
+			if from < textStart && textStart < to {
+				end = textStart
+			}
+			seg := line[from:end]
+			switch {
+			case matched:
+				sb.WriteString(matchStyle.Render(seg))
+			case styled && from >= textStart:
+				sb.WriteString(textStyle.Render(seg))
+			default:
+				sb.WriteString(seg)
+			}
+			from = end
+		}
+	}
+
+	pos := 0
+	if pattern != nil {
+		for _, m := range pattern.FindAllStringIndex(line, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			render(pos, m[0], false)
+			render(m[0], m[1], true)
+			pos = m[1]
+		}
+	}
+	render(pos, len(line), false)
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+package lb
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Faults are injected into every request a service's load balancer handles,
+// before it is proxied: Latency delays the request and ErrorRate is the
+// fraction of requests answered with ErrorStatus instead of being proxied.
+type Faults struct {
+	Latency     time.Duration
+	ErrorRate   float64
+	ErrorStatus int
+}
+
+func (f Faults) Active() bool {
+	return f.Latency > 0 || f.ErrorRate > 0
+}
+
+func (s *ServiceLB) Faults() Faults {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.faults
+}
+
+func (s *ServiceLB) SetFaults(f Faults) {
+	if f.ErrorStatus == 0 {
+		f.ErrorStatus = http.StatusServiceUnavailable
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = f
+}
+
+// injectFaults applies the service's faults to r and reports whether the
+// request was answered with an injected error.
+func (s *ServiceLB) injectFaults(w http.ResponseWriter, r *http.Request) bool {
+	f := s.Faults()
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if f.ErrorRate > 0 && rand.Float64() < f.ErrorRate {
+		http.Error(w, "Injected fault", f.ErrorStatus)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package lb
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+type healthState struct {
+	successes int
+	failures  int
+}
+
+// runHealthChecks probes every backend of the service on each interval and
+// flips it up or down once the configured threshold of consecutive results
+// is reached.
+func (s *ServiceLB) runHealthChecks(ctx context.Context, hc config.HealthCheck) {
+	client := &http.Client{Timeout: hc.Timeout}
+	states := make(map[*Backend]*healthState)
+
+	ticker := time.NewTicker(hc.Interval)
+	defer ticker.Stop()
+
+	log.Printf("[LB] Health checking %s on %s every %s", s.Name, hc.Path, hc.Interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.RLock()
+		backends := make([]*Backend, len(s.Backends))
+		copy(backends, s.Backends)
+		s.mu.RUnlock()
+
+		results := make([]bool, len(backends))
+		var wg sync.WaitGroup
+		for i, b := range backends {
+			wg.Add(1)
+			go func(i int, b *Backend) {
+				defer wg.Done()
+				results[i] = probe(ctx, client, b, hc.Path)
+			}(i, b)
+		}
+		wg.Wait()
+
+		seen := make(map[*Backend]*healthState, len(backends))
+		for i, b := range backends {
+			st, ok := states[b]
+			if !ok {
+				st = &healthState{}
+			}
+			seen[b] = st
+			s.recordResult(b, st, results[i], hc)
+		}
+		states = seen
+	}
+}
+
+func (s *ServiceLB) recordResult(b *Backend, st *healthState, ok bool, hc config.HealthCheck) {
+	if ok {
+		st.successes++
+		st.failures = 0
+		if !b.Healthy() && st.successes >= hc.HealthyThreshold {
+			s.setHealthy(b, true)
+			log.Printf("[LB] Backend %s (%s) for %s is UP (%d consecutive successes)", b.Name, b.URL, s.Name, st.successes)
+		}
+		return
+	}
+
+	st.failures++
+	st.successes = 0
+	if b.Healthy() && st.failures >= hc.UnhealthyThreshold {
+		s.setHealthy(b, false)
+		log.Printf("[LB] Backend %s (%s) for %s is DOWN (%d consecutive failures)", b.Name, b.URL, s.Name, st.failures)
+	}
+}
+
+func (s *ServiceLB) setHealthy(b *Backend, healthy bool) {
+	b.healthy.Store(healthy)
+
+	s.mu.RLock()
+	reg := s.registry
+	s.mu.RUnlock()
+	if reg != nil {
+		reg.SetHealthy(s.Name, b.Name, healthy)
+	}
+}
+
+func probe(ctx context.Context, client *http.Client, b *Backend, path string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL.String()+path, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
@@ -0,0 +1,116 @@
+package lb
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/metrics"
+)
+
+// serviceStats counts the requests a service's load balancer has handled.
+// Requests that never reached a backend (injected faults, no healthy
+// backend) are recorded with an empty backend name.
+type serviceStats struct {
+	inFlight atomic.Int64
+
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	latency  map[string]*metrics.Histogram
+}
+
+type requestKey struct {
+	backend string
+	class   string
+}
+
+func newServiceStats() *serviceStats {
+	return &serviceStats{
+		requests: make(map[requestKey]uint64),
+		latency:  make(map[string]*metrics.Histogram),
+	}
+}
+
+func (st *serviceStats) observe(backend string, status int, d time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.requests[requestKey{backend, fmt.Sprintf("%dxx", status/100)}]++
+	h, ok := st.latency[backend]
+	if !ok {
+		h = metrics.NewHistogram(metrics.DefaultBuckets)
+		st.latency[backend] = h
+	}
+	h.Observe(d)
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, which the
+// reverse proxy uses to flush streamed responses.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// WriteMetrics writes request counts, latencies, in-flight requests and
+// backend health for every service.
+func (lb *LoadBalancer) WriteMetrics(w *metrics.Writer) {
+	services := lb.Services()
+
+	w.Header("go_sim_lb_requests_total", "counter", "Requests handled by the load balancer, by backend and status class.")
+	for _, slb := range services {
+		slb.stats.mu.Lock()
+		keys := slices.SortedFunc(maps.Keys(slb.stats.requests), func(a, b requestKey) int {
+			return cmp.Or(cmp.Compare(a.backend, b.backend), cmp.Compare(a.class, b.class))
+		})
+		for _, k := range keys {
+			w.Sample("go_sim_lb_requests_total", float64(slb.stats.requests[k]), "service", slb.Name, "backend", k.backend, "code", k.class)
+		}
+		slb.stats.mu.Unlock()
+	}
+
+	w.Header("go_sim_lb_request_duration_seconds", "histogram", "Time to answer a request, including injected latency.")
+	for _, slb := range services {
+		slb.stats.mu.Lock()
+		for _, backend := range slices.Sorted(maps.Keys(slb.stats.latency)) {
+			w.Histogram("go_sim_lb_request_duration_seconds", slb.stats.latency[backend], "service", slb.Name, "backend", backend)
+		}
+		slb.stats.mu.Unlock()
+	}
+
+	w.Header("go_sim_lb_in_flight_requests", "gauge", "Requests currently being handled, by service.")
+	for _, slb := range services {
+		w.Sample("go_sim_lb_in_flight_requests", float64(slb.stats.inFlight.Load()), "service", slb.Name)
+	}
+
+	w.Header("go_sim_lb_backend_in_flight_requests", "gauge", "Requests currently proxied to each backend.")
+	for _, slb := range services {
+		for _, b := range slb.BackendList() {
+			w.Sample("go_sim_lb_backend_in_flight_requests", float64(b.ActiveConnections()), "service", slb.Name, "backend", b.Name)
+		}
+	}
+
+	w.Header("go_sim_lb_backend_healthy", "gauge", "Whether a backend passes its health checks (1) or not (0).")
+	for _, slb := range services {
+		for _, b := range slb.BackendList() {
+			healthy := 0.0
+			if b.Healthy() {
+				healthy = 1
+			}
+			w.Sample("go_sim_lb_backend_healthy", healthy, "service", slb.Name, "backend", b.Name)
+		}
+	}
+}
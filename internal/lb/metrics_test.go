@@ -0,0 +1,60 @@
+package lb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/metrics"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/registry"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+
+	cfg := config.Config{
+		LBPort:   50110,
+		Services: map[string]config.Service{"api": {Name: "api", RoutePrefix: "/api"}},
+	}
+	lb, err := NewLoadBalancer(cfg, registry.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slb := lb.Service("api")
+
+	// No backends yet: answered by the load balancer itself.
+	slb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	u, _ := url.Parse(backend.URL)
+	slb.AddBackend("api-1", u)
+	for _, path := range []string{"/", "/", "/missing"} {
+		slb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	var sb strings.Builder
+	lb.WriteMetrics(metrics.NewWriter(&sb))
+	out := sb.String()
+
+	for _, want := range []string{
+		`go_sim_lb_requests_total{service="api",backend="",code="5xx"} 1`,
+		`go_sim_lb_requests_total{service="api",backend="api-1",code="2xx"} 2`,
+		`go_sim_lb_requests_total{service="api",backend="api-1",code="4xx"} 1`,
+		`go_sim_lb_request_duration_seconds_count{service="api",backend="api-1"} 3`,
+		`go_sim_lb_request_duration_seconds_bucket{service="api",backend="api-1",le="+Inf"} 3`,
+		`go_sim_lb_in_flight_requests{service="api"} 0`,
+		`go_sim_lb_backend_healthy{service="api",backend="api-1"} 1`,
+		"# TYPE go_sim_lb_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
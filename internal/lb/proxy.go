@@ -2,51 +2,208 @@ package lb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/registry"
 )
 
 type Backend struct {
+	Name         string
 	URL          *url.URL
 	ReverseProxy *httputil.ReverseProxy
+
+	healthy atomic.Bool
+	active  atomic.Int64
+}
+
+func NewBackend(name string, targetURL *url.URL) *Backend {
+	b := &Backend{
+		Name:         name,
+		URL:          targetURL,
+		ReverseProxy: httputil.NewSingleHostReverseProxy(targetURL),
+	}
+	b.healthy.Store(true)
+	return b
+}
+
+func (b *Backend) Healthy() bool {
+	return b.healthy.Load()
+}
+
+func (b *Backend) ActiveConnections() int64 {
+	return b.active.Load()
 }
 
 type ServiceLB struct {
-	Backends []Backend
-	current  uint64
+	Name     string
+	Backends []*Backend
+	strategy Strategy
+	registry *registry.Registry
+	faults   Faults
+	stats    *serviceStats
 	mu       sync.RWMutex
 }
 
-func (s *ServiceLB) NextBackend() *Backend {
+func NewServiceLB(name string, strategy Strategy) *ServiceLB {
+	if strategy == nil {
+		strategy = &roundRobin{}
+	}
+	return &ServiceLB{
+		Name:     name,
+		strategy: strategy,
+		stats:    newServiceStats(),
+	}
+}
+
+func (s *ServiceLB) Strategy() Strategy {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if len(s.Backends) == 0 {
+	return s.strategy
+}
+
+func (s *ServiceLB) SetStrategy(strategy Strategy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strategy = strategy
+}
+
+// BackendList returns a snapshot of the service's backends.
+func (s *ServiceLB) BackendList() []*Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	backends := make([]*Backend, len(s.Backends))
+	copy(backends, s.Backends)
+	return backends
+}
+
+// NextBackend asks the service's strategy for a backend among the healthy
+// ones, or returns nil if every backend is currently marked down.
+func (s *ServiceLB) NextBackend(r *http.Request) *Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	healthy := make([]*Backend, 0, len(s.Backends))
+	for _, b := range s.Backends {
+		if b.Healthy() {
+			healthy = append(healthy, b)
+		}
+	}
+	if len(healthy) == 0 {
 		return nil
 	}
-	idx := atomic.AddUint64(&s.current, 1) % uint64(len(s.Backends))
-	return &s.Backends[idx]
+	return s.strategy.Next(healthy, r)
+}
+
+// AddBackend adds a backend to the rotation, replacing any existing backend
+// with the same name.
+func (s *ServiceLB) AddBackend(name string, targetURL *url.URL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.Backends {
+		if b.Name == name {
+			s.Backends[i] = NewBackend(name, targetURL)
+			return
+		}
+	}
+	s.Backends = append(s.Backends, NewBackend(name, targetURL))
+}
+
+func (s *ServiceLB) RemoveBackend(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.Backends {
+		if b.Name == name {
+			s.Backends = append(s.Backends[:i:i], s.Backends[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// syncWith keeps the backend pool in step with the service's endpoints in reg.
+func (s *ServiceLB) syncWith(reg *registry.Registry) {
+	s.mu.Lock()
+	s.registry = reg
+	s.mu.Unlock()
+
+	endpoints := reg.Subscribe(s.Name, func(ev registry.Event) {
+		switch ev.Type {
+		case registry.EndpointAdded:
+			s.AddBackend(ev.Endpoint.Replica, ev.Endpoint.URL)
+			log.Printf("[LB] Added backend %s (%s) to %s", ev.Endpoint.Replica, ev.Endpoint.URL, s.Name)
+		case registry.EndpointRemoved:
+			if s.RemoveBackend(ev.Endpoint.Replica) {
+				log.Printf("[LB] Removed backend %s (%s) from %s", ev.Endpoint.Replica, ev.Endpoint.URL, s.Name)
+			}
+		}
+	})
+	for _, ep := range endpoints {
+		s.AddBackend(ep.Replica, ep.URL)
+	}
 }
 
 func (s *ServiceLB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	backend := s.NextBackend()
+	start := time.Now()
+	s.stats.inFlight.Add(1)
+	defer s.stats.inFlight.Add(-1)
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	backend := s.serve(rec, r)
+	s.stats.observe(backend, rec.status, time.Since(start))
+}
+
+// serve answers r and returns the name of the backend it was proxied to, or
+// "" if it wasn't.
+func (s *ServiceLB) serve(w http.ResponseWriter, r *http.Request) string {
+	if s.injectFaults(w, r) {
+		return ""
+	}
+	backend := s.NextBackend(r)
 	if backend == nil {
 		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
-		return
+		return ""
 	}
 	log.Printf("[LB] Routing %s to %s", r.URL.Path, backend.URL.String())
+	backend.active.Add(1)
+	defer backend.active.Add(-1)
 	backend.ReverseProxy.ServeHTTP(w, r)
+	return backend.Name
 }
 
-func StartLB(ctx context.Context, cfg config.Config) error {
-	mux := http.NewServeMux()
+// LoadBalancer routes each configured service's route prefix to its
+// ServiceLB and keeps them around so strategies and faults can be changed
+// while it runs.
+type LoadBalancer struct {
+	port     int
+	services map[string]*ServiceLB
+	configs  map[string]config.Service
+	mux      *http.ServeMux
+}
+
+// NewLoadBalancer sets up a ServiceLB per configured service. Each service's
+// backend pool follows the endpoints the runner publishes to reg, so the
+// ports in the pool are the ones replicas were actually assigned.
+func NewLoadBalancer(cfg config.Config, reg *registry.Registry) (*LoadBalancer, error) {
+	if reg == nil {
+		return nil, errors.New("load balancer needs a backend registry")
+	}
+
+	lb := &LoadBalancer{
+		port:     cfg.LBPort,
+		services: make(map[string]*ServiceLB),
+		configs:  make(map[string]config.Service),
+		mux:      http.NewServeMux(),
+	}
 	registered := make(map[string]bool)
 
 	for _, svc := range cfg.Services {
@@ -54,41 +211,81 @@ func StartLB(ctx context.Context, cfg config.Config) error {
 			log.Printf("[LB] Warning: Route prefix %q for service %q is already registered. Skipping.", svc.RoutePrefix, svc.Name)
 			continue
 		}
-		
-		slb := &ServiceLB{
-			Backends: make([]Backend, 0, svc.Replicas),
-		}
-
-		for i := 0; i < svc.Replicas; i++ {
-			port := svc.StartPort + i
-			targetURL, err := url.Parse(fmt.Sprintf("http://localhost:%d", port))
-			if err != nil {
-				return fmt.Errorf("failed to parse backend url: %w", err)
-			}
 
-			proxy := httputil.NewSingleHostReverseProxy(targetURL)
-
-			slb.Backends = append(slb.Backends, Backend{
-				URL:          targetURL,
-				ReverseProxy: proxy,
-			})
+		strategy, err := NewStrategy(svc.LBStrategy, svc.LBWeights, svc.LBHashHeader)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
 		}
+		slb := NewServiceLB(svc.Name, strategy)
 
+		slb.syncWith(reg)
 
 		routePattern := svc.RoutePrefix
 		if !strings.HasSuffix(routePattern, "/") {
 			routePattern += "/"
 		}
 
-		mux.Handle(routePattern, http.StripPrefix(svc.RoutePrefix, slb))
+		lb.mux.Handle(routePattern, http.StripPrefix(svc.RoutePrefix, slb))
+		lb.services[svc.Name] = slb
+		lb.configs[svc.Name] = svc
 		registered[svc.RoutePrefix] = true
-		
-		log.Printf("[LB] Registered service %s at %s with %d replicas", svc.Name, routePattern, svc.Replicas)
+
+		log.Printf("[LB] Registered service %s at %s with %d backends (%s)", svc.Name, routePattern, len(slb.Backends), strategy.Name())
+	}
+
+	return lb, nil
+}
+
+// Service returns the load balancer of the named service, or nil.
+func (lb *LoadBalancer) Service(name string) *ServiceLB {
+	return lb.services[name]
+}
+
+// Services returns every service's load balancer, sorted by name.
+func (lb *LoadBalancer) Services() []*ServiceLB {
+	services := make([]*ServiceLB, 0, len(lb.services))
+	for _, slb := range lb.services {
+		services = append(services, slb)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services
+}
+
+// SetStrategy switches a service to the named strategy. Weights and the hash
+// header come from the service's config unless given.
+func (lb *LoadBalancer) SetStrategy(service, name string, weights map[string]int, hashHeader string) error {
+	slb, ok := lb.services[service]
+	if !ok {
+		return fmt.Errorf("service %s not found", service)
+	}
+	svc := lb.configs[service]
+	if weights == nil {
+		weights = svc.LBWeights
+	}
+	if hashHeader == "" {
+		hashHeader = svc.LBHashHeader
+	}
+	strategy, err := NewStrategy(name, weights, hashHeader)
+	if err != nil {
+		return err
+	}
+	slb.SetStrategy(strategy)
+	log.Printf("[LB] Switched %s to %s", service, strategy.Name())
+	return nil
+}
+
+// Run starts the health checks and serves on the configured port until ctx
+// is cancelled.
+func (lb *LoadBalancer) Run(ctx context.Context) error {
+	for name, slb := range lb.services {
+		if hc := lb.configs[name].HealthCheck; hc != nil {
+			go slb.runHealthChecks(ctx, hc.WithDefaults())
+		}
 	}
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.LBPort),
-		Handler: mux,
+		Addr:    fmt.Sprintf(":%d", lb.port),
+		Handler: lb.mux,
 	}
 
 	go func() {
@@ -97,10 +294,20 @@ func StartLB(ctx context.Context, cfg config.Config) error {
 		server.Shutdown(context.Background())
 	}()
 
-	log.Printf("[LB] Starting Load Balancer on port %d", cfg.LBPort)
+	log.Printf("[LB] Starting Load Balancer on port %d", lb.port)
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		return err
 	}
 
 	return nil
 }
+
+// StartLB serves every configured service on cfg.LBPort until ctx is
+// cancelled.
+func StartLB(ctx context.Context, cfg config.Config, reg *registry.Registry) error {
+	lb, err := NewLoadBalancer(cfg, reg)
+	if err != nil {
+		return err
+	}
+	return lb.Run(ctx)
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/registry"
 )
 
 func startMockServer(port int, id string) *http.Server {
@@ -26,6 +27,14 @@ func startMockServer(port int, id string) *http.Server {
 	return server
 }
 
+func registerBackends(t *testing.T, reg *registry.Registry, service string, startPort, n int) {
+	for i := 0; i < n; i++ {
+		if err := reg.Register(service, fmt.Sprintf("%s-%d", service, i+1), startPort+i); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+}
+
 func TestRoundRobinLoadBalancer(t *testing.T) {
 	startPort := 50000
 	lbPort := 50005
@@ -43,18 +52,19 @@ func TestRoundRobinLoadBalancer(t *testing.T) {
 		Services: map[string]config.Service{
 			"test-service": {
 				Name:        "test-service",
-				StartPort:   startPort,
-				Replicas:    replicas,
 				RoutePrefix: "/test",
 			},
 		},
 	}
 
+	reg := registry.New()
+	registerBackends(t, reg, "test-service", startPort, replicas)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	
+
 	go func() {
-		err := StartLB(ctx, cfg)
+		err := StartLB(ctx, cfg, reg)
 		if err != nil {
 			fmt.Printf("LB Error: %v\n", err)
 		}
@@ -73,7 +83,120 @@ func TestRoundRobinLoadBalancer(t *testing.T) {
 		}
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		
+
 		fmt.Printf("Req %d: %s\n", i, string(body))
 	}
 }
+
+func TestHealthCheckSkipsDownBackend(t *testing.T) {
+	startPort := 50010
+	lbPort := 50015
+
+	srv1 := startMockServer(startPort, "backend-1")
+	defer srv1.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	cfg := config.Config{
+		LBPort: lbPort,
+		Services: map[string]config.Service{
+			"test-service": {
+				Name:        "test-service",
+				RoutePrefix: "/test",
+				HealthCheck: &config.HealthCheck{
+					Path:               "/",
+					Interval:           20 * time.Millisecond,
+					Timeout:            50 * time.Millisecond,
+					UnhealthyThreshold: 1,
+				},
+			},
+		},
+	}
+
+	reg := registry.New()
+	registerBackends(t, reg, "test-service", startPort, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := StartLB(ctx, cfg, reg); err != nil {
+			fmt.Printf("LB Error: %v\n", err)
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	lbURL := fmt.Sprintf("http://localhost:%d/test", lbPort)
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get(lbURL)
+		if err != nil {
+			t.Fatalf("Request %d failed: %v", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || string(body) != "Response from backend-1" {
+			t.Fatalf("Request %d: expected backend-1, got %d %q", i, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestRegistryUpdatesBackendPool(t *testing.T) {
+	backendPort := 50020
+	lbPort := 50025
+
+	srv := startMockServer(backendPort, "backend-1")
+	defer srv.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	cfg := config.Config{
+		LBPort: lbPort,
+		Services: map[string]config.Service{
+			"test-service": {
+				Name:        "test-service",
+				RoutePrefix: "/test",
+			},
+		},
+	}
+
+	reg := registry.New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := StartLB(ctx, cfg, reg); err != nil {
+			fmt.Printf("LB Error: %v\n", err)
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	lbURL := fmt.Sprintf("http://localhost:%d/test", lbPort)
+	status := func() int {
+		resp, err := http.Get(lbURL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with no backends, got %d", got)
+	}
+
+	if err := reg.Register("test-service", "test-service-1", backendPort); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := status(); got != http.StatusOK {
+		t.Fatalf("expected 200 after register, got %d", got)
+	}
+
+	reg.Deregister("test-service", "test-service-1")
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 after deregister, got %d", got)
+	}
+}
@@ -0,0 +1,177 @@
+package lb
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+// Strategy picks the backend for a request. It is only ever handed healthy
+// backends and is never called with an empty slice.
+type Strategy interface {
+	Name() string
+	Next(backends []*Backend, r *http.Request) *Backend
+}
+
+// NewStrategy builds the strategy called name. weights and hashHeader are
+// only used by the weighted round-robin and consistent-hash strategies.
+func NewStrategy(name string, weights map[string]int, hashHeader string) (Strategy, error) {
+	switch name {
+	case "", config.StrategyRoundRobin:
+		return &roundRobin{}, nil
+	case config.StrategyLeastConnections:
+		return &leastConnections{}, nil
+	case config.StrategyRandom:
+		return random{}, nil
+	case config.StrategyWeightedRoundRobin:
+		return &weightedRoundRobin{weights: weights, current: make(map[*Backend]int)}, nil
+	case config.StrategyIPHash:
+		return ipHash{}, nil
+	case config.StrategyConsistentHash:
+		if hashHeader == "" {
+			return nil, fmt.Errorf("%s strategy needs a hash header", name)
+		}
+		return consistentHash{header: hashHeader}, nil
+	default:
+		return nil, fmt.Errorf("unknown lb strategy %q", name)
+	}
+}
+
+type roundRobin struct {
+	current uint64
+}
+
+func (s *roundRobin) Name() string { return config.StrategyRoundRobin }
+
+func (s *roundRobin) Next(backends []*Backend, _ *http.Request) *Backend {
+	idx := atomic.AddUint64(&s.current, 1) % uint64(len(backends))
+	return backends[idx]
+}
+
+// leastConnections picks the backend with the fewest in-flight requests,
+// rotating the starting point so ties are spread out.
+type leastConnections struct {
+	current uint64
+}
+
+func (s *leastConnections) Name() string { return config.StrategyLeastConnections }
+
+func (s *leastConnections) Next(backends []*Backend, _ *http.Request) *Backend {
+	n := uint64(len(backends))
+	start := atomic.AddUint64(&s.current, 1)
+	best := backends[start%n]
+	for i := uint64(1); i < n; i++ {
+		b := backends[(start+i)%n]
+		if b.ActiveConnections() < best.ActiveConnections() {
+			best = b
+		}
+	}
+	return best
+}
+
+type random struct{}
+
+func (random) Name() string { return config.StrategyRandom }
+
+func (random) Next(backends []*Backend, _ *http.Request) *Backend {
+	return backends[rand.IntN(len(backends))]
+}
+
+// weightedRoundRobin is the smooth weighted round-robin used by nginx:
+// backends are interleaved in proportion to their weight instead of being
+// picked in bursts. Backends without a configured weight count as 1.
+type weightedRoundRobin struct {
+	weights map[string]int
+	current map[*Backend]int
+	mu      sync.Mutex
+}
+
+func (s *weightedRoundRobin) Name() string { return config.StrategyWeightedRoundRobin }
+
+func (s *weightedRoundRobin) Next(backends []*Backend, _ *http.Request) *Backend {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var best *Backend
+	total := 0
+	live := make(map[*Backend]int, len(backends))
+	for _, b := range backends {
+		w := s.weight(b)
+		total += w
+		live[b] = s.current[b] + w
+		if best == nil || live[b] > live[best] {
+			best = b
+		}
+	}
+	live[best] -= total
+	s.current = live
+	return best
+}
+
+func (s *weightedRoundRobin) weight(b *Backend) int {
+	if w, ok := s.weights[b.Name]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// ipHash pins each client IP to a backend for as long as the healthy set
+// doesn't change.
+type ipHash struct{}
+
+func (ipHash) Name() string { return config.StrategyIPHash }
+
+func (ipHash) Next(backends []*Backend, r *http.Request) *Backend {
+	return backends[hashString(clientIP(r))%uint64(len(backends))]
+}
+
+// consistentHash routes by the value of a request header using rendezvous
+// hashing, so adding or removing a backend only moves the keys that mapped
+// to it. Requests without the header fall back to the client IP.
+type consistentHash struct {
+	header string
+}
+
+func (consistentHash) Name() string { return config.StrategyConsistentHash }
+
+func (s consistentHash) Next(backends []*Backend, r *http.Request) *Backend {
+	key := r.Header.Get(s.header)
+	if key == "" {
+		key = clientIP(r)
+	}
+
+	var best *Backend
+	var bestScore uint64
+	for _, b := range backends {
+		score := hashString(b.Name + "|" + key)
+		if best == nil || score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		ip, _, _ := strings.Cut(fwd, ",")
+		return strings.TrimSpace(ip)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
@@ -0,0 +1,112 @@
+package lb
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+func testBackends(n int) []*Backend {
+	backends := make([]*Backend, n)
+	for i := range backends {
+		u, _ := url.Parse(fmt.Sprintf("http://localhost:%d", 9000+i))
+		backends[i] = NewBackend(fmt.Sprintf("svc-%d", i+1), u)
+	}
+	return backends
+}
+
+func TestRoundRobinStrategy(t *testing.T) {
+	s, _ := NewStrategy(config.StrategyRoundRobin, nil, "")
+	backends := testBackends(3)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	counts := map[string]int{}
+	for i := 0; i < 9; i++ {
+		counts[s.Next(backends, req).Name]++
+	}
+	for _, b := range backends {
+		if counts[b.Name] != 3 {
+			t.Fatalf("expected 3 requests on %s, got %d", b.Name, counts[b.Name])
+		}
+	}
+}
+
+func TestLeastConnectionsStrategy(t *testing.T) {
+	s, _ := NewStrategy(config.StrategyLeastConnections, nil, "")
+	backends := testBackends(3)
+	backends[0].active.Store(5)
+	backends[1].active.Store(1)
+	backends[2].active.Store(3)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	for i := 0; i < 5; i++ {
+		if got := s.Next(backends, req).Name; got != "svc-2" {
+			t.Fatalf("expected svc-2, got %s", got)
+		}
+	}
+}
+
+func TestWeightedRoundRobinStrategy(t *testing.T) {
+	s, _ := NewStrategy(config.StrategyWeightedRoundRobin, map[string]int{"svc-1": 3}, "")
+	backends := testBackends(2)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		counts[s.Next(backends, req).Name]++
+	}
+	if counts["svc-1"] != 6 || counts["svc-2"] != 2 {
+		t.Fatalf("expected 6/2 split, got %v", counts)
+	}
+}
+
+func TestIPHashStrategy(t *testing.T) {
+	s, _ := NewStrategy(config.StrategyIPHash, nil, "")
+	backends := testBackends(5)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.7:51234"
+
+	first := s.Next(backends, req)
+	for i := 0; i < 10; i++ {
+		req.RemoteAddr = fmt.Sprintf("10.0.0.7:%d", 40000+i)
+		if got := s.Next(backends, req); got != first {
+			t.Fatalf("expected same backend for same IP, got %s and %s", first.Name, got.Name)
+		}
+	}
+}
+
+func TestConsistentHashStrategy(t *testing.T) {
+	s, err := NewStrategy(config.StrategyConsistentHash, nil, "X-User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := testBackends(5)
+
+	assigned := map[string]*Backend{}
+	for i := 0; i < 50; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+		assigned[req.Header.Get("X-User")] = s.Next(backends, req)
+	}
+
+	// Dropping one backend must only move the keys that were on it.
+	removed := backends[2]
+	remaining := append(append([]*Backend{}, backends[:2]...), backends[3:]...)
+	for key, before := range assigned {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User", key)
+		after := s.Next(remaining, req)
+		if before != removed && after != before {
+			t.Fatalf("key %s moved from %s to %s", key, before.Name, after.Name)
+		}
+	}
+}
+
+func TestUnknownStrategy(t *testing.T) {
+	if _, err := NewStrategy("fastest", nil, ""); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
@@ -0,0 +1,135 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+type Endpoint struct {
+	Service string
+	Replica string
+	URL     *url.URL
+	Healthy bool
+}
+
+type EventType int
+
+const (
+	EndpointAdded EventType = iota
+	EndpointRemoved
+)
+
+type Event struct {
+	Type     EventType
+	Endpoint Endpoint
+}
+
+// Registry is the shared view of which replica endpoints are live. The runner
+// publishes to it and the load balancer subscribes to it.
+type Registry struct {
+	endpoints   map[string]map[string]Endpoint
+	subscribers map[string][]func(Event)
+	mu          sync.Mutex
+}
+
+func New() *Registry {
+	return &Registry{
+		endpoints:   make(map[string]map[string]Endpoint),
+		subscribers: make(map[string][]func(Event)),
+	}
+}
+
+// Register adds or replaces the endpoint of a replica and notifies the
+// service's subscribers.
+func (r *Registry) Register(service, replica string, port int) error {
+	u, err := url.Parse(fmt.Sprintf("http://localhost:%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to parse endpoint url: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	eps, ok := r.endpoints[service]
+	if !ok {
+		eps = make(map[string]Endpoint)
+		r.endpoints[service] = eps
+	}
+	if old, ok := eps[replica]; ok {
+		if old.URL.String() == u.String() {
+			return nil
+		}
+		r.notify(Event{Type: EndpointRemoved, Endpoint: old})
+	}
+
+	ep := Endpoint{Service: service, Replica: replica, URL: u, Healthy: true}
+	eps[replica] = ep
+	r.notify(Event{Type: EndpointAdded, Endpoint: ep})
+	return nil
+}
+
+// Deregister removes a replica's endpoint. Unknown replicas are ignored.
+func (r *Registry) Deregister(service, replica string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ep, ok := r.endpoints[service][replica]
+	if !ok {
+		return
+	}
+	delete(r.endpoints[service], replica)
+	r.notify(Event{Type: EndpointRemoved, Endpoint: ep})
+}
+
+// SetHealthy records the outcome of the load balancer's health checks for a
+// replica. It does not notify subscribers.
+func (r *Registry) SetHealthy(service, replica string, healthy bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ep, ok := r.endpoints[service][replica]; ok {
+		ep.Healthy = healthy
+		r.endpoints[service][replica] = ep
+	}
+}
+
+// Healthy reports whether a registered replica passes its health checks.
+// Replicas that aren't registered count as healthy.
+func (r *Registry) Healthy(service, replica string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ep, ok := r.endpoints[service][replica]
+	return !ok || ep.Healthy
+}
+
+func (r *Registry) Endpoints(service string) []Endpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshot(service)
+}
+
+// Subscribe registers fn for changes to service and returns the endpoints
+// registered at the time of subscribing, so no event is missed in between.
+// fn is called with the registry locked and must not call back into it.
+func (r *Registry) Subscribe(service string, fn func(Event)) []Endpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers[service] = append(r.subscribers[service], fn)
+	return r.snapshot(service)
+}
+
+func (r *Registry) snapshot(service string) []Endpoint {
+	eps := make([]Endpoint, 0, len(r.endpoints[service]))
+	for _, ep := range r.endpoints[service] {
+		eps = append(eps, ep)
+	}
+	sort.Slice(eps, func(i, j int) bool { return eps[i].Replica < eps[j].Replica })
+	return eps
+}
+
+func (r *Registry) notify(ev Event) {
+	for _, fn := range r.subscribers[ev.Endpoint.Service] {
+		fn(ev)
+	}
+}
@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"fmt"
+	"testing"
+)
+
+// record subscribes to service and returns the events it receives as
+// "+replica url" and "-replica url".
+func record(r *Registry, service string) (*[]string, []Endpoint) {
+	var events []string
+	initial := r.Subscribe(service, func(ev Event) {
+		op := "+"
+		if ev.Type == EndpointRemoved {
+			op = "-"
+		}
+		events = append(events, fmt.Sprintf("%s%s %s", op, ev.Endpoint.Replica, ev.Endpoint.URL))
+	})
+	return &events, initial
+}
+
+func TestRegisterAndDeregister(t *testing.T) {
+	r := New()
+	if err := r.Register("api", "api-2", 9002); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("api", "api-1", 9001); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("web", "web-1", 9101); err != nil {
+		t.Fatal(err)
+	}
+
+	eps := r.Endpoints("api")
+	if len(eps) != 2 || eps[0].Replica != "api-1" || eps[1].Replica != "api-2" {
+		t.Fatalf("expected api-1 and api-2 sorted by replica, got %+v", eps)
+	}
+	if eps[0].URL.String() != "http://localhost:9001" || !eps[0].Healthy {
+		t.Errorf("unexpected endpoint: %+v", eps[0])
+	}
+
+	r.Deregister("api", "api-1")
+	r.Deregister("api", "missing")
+	r.Deregister("missing", "api-2")
+	if eps := r.Endpoints("api"); len(eps) != 1 || eps[0].Replica != "api-2" {
+		t.Errorf("expected only api-2 after deregistering api-1, got %+v", eps)
+	}
+	if eps := r.Endpoints("web"); len(eps) != 1 {
+		t.Errorf("expected web to be untouched, got %+v", eps)
+	}
+}
+
+func TestSetHealthy(t *testing.T) {
+	r := New()
+	if err := r.Register("api", "api-1", 9001); err != nil {
+		t.Fatal(err)
+	}
+	events, _ := record(r, "api")
+
+	r.SetHealthy("api", "api-1", false)
+	if r.Healthy("api", "api-1") {
+		t.Error("expected api-1 to be unhealthy")
+	}
+	if eps := r.Endpoints("api"); eps[0].Healthy {
+		t.Error("expected the endpoint to be reported unhealthy")
+	}
+	r.SetHealthy("api", "api-1", true)
+	if !r.Healthy("api", "api-1") {
+		t.Error("expected api-1 to be healthy again")
+	}
+	if len(*events) != 0 {
+		t.Errorf("SetHealthy should not notify subscribers, got %v", *events)
+	}
+
+	// Unknown replicas count as healthy and aren't added.
+	r.SetHealthy("api", "api-9", false)
+	if !r.Healthy("api", "api-9") {
+		t.Error("expected an unregistered replica to count as healthy")
+	}
+	if eps := r.Endpoints("api"); len(eps) != 1 {
+		t.Errorf("SetHealthy should not register replicas, got %+v", eps)
+	}
+
+	// Re-registering on a new port resets the health.
+	r.SetHealthy("api", "api-1", false)
+	if err := r.Register("api", "api-1", 9005); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Healthy("api", "api-1") {
+		t.Error("expected a re-registered replica to start healthy")
+	}
+}
+
+func TestSubscriberNotifications(t *testing.T) {
+	r := New()
+	if err := r.Register("api", "api-1", 9001); err != nil {
+		t.Fatal(err)
+	}
+
+	events, initial := record(r, "api")
+	other, _ := record(r, "web")
+	if len(initial) != 1 || initial[0].Replica != "api-1" {
+		t.Fatalf("expected the snapshot to hold api-1, got %+v", initial)
+	}
+
+	r.Register("api", "api-2", 9002)
+	// Same endpoint again: nothing changes.
+	r.Register("api", "api-2", 9002)
+	// New port: the old endpoint goes before the new one arrives.
+	r.Register("api", "api-1", 9003)
+	r.Deregister("api", "api-2")
+	r.Deregister("api", "api-2")
+
+	want := []string{
+		"+api-2 http://localhost:9002",
+		"-api-1 http://localhost:9001",
+		"+api-1 http://localhost:9003",
+		"-api-2 http://localhost:9002",
+	}
+	if fmt.Sprint(*events) != fmt.Sprint(want) {
+		t.Errorf("unexpected events:\n got %v\nwant %v", *events, want)
+	}
+	if len(*other) != 0 {
+		t.Errorf("subscriber of another service got %v", *other)
+	}
+
+	// Every subscriber of a service sees every event, in subscription order.
+	var order []string
+	r.Subscribe("api", func(Event) { order = append(order, "first") })
+	r.Subscribe("api", func(Event) { order = append(order, "second") })
+	r.Deregister("api", "api-1")
+	if fmt.Sprint(order) != "[first second]" {
+		t.Errorf("expected subscribers to be called in order, got %v", order)
+	}
+	if got := (*events)[len(*events)-1]; got != "-api-1 http://localhost:9003" {
+		t.Errorf("expected the earlier subscriber to see the removal too, got %q", got)
+	}
+}
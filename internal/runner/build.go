@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+// Builder compiles services configured by path once into a cache directory
+// so every replica can exec the same binary instead of running `go run` on
+// its own.
+type Builder struct {
+	cacheDir string
+	mu       sync.Mutex
+}
+
+func NewBuilder(cacheDir string) *Builder {
+	return &Builder{cacheDir: cacheDir}
+}
+
+func DefaultBuildCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "go-sim", "bin")
+}
+
+// Build returns the path of an up to date binary for svc, compiling it only
+// when no binary for the current source hash is cached yet.
+func (b *Builder) Build(svc config.Service) (string, error) {
+	cacheDir, err := filepath.Abs(b.cacheDir)
+	if err != nil {
+		return "", fmt.Errorf("build %s: %w", svc.Name, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hash, err := sourceHash(svc.Path, svc.WorkingDir)
+	if err != nil {
+		return "", fmt.Errorf("build %s: %w", svc.Name, err)
+	}
+
+	binary := filepath.Join(cacheDir, fmt.Sprintf("%s-%s", svc.Name, hash[:16]))
+	if _, err := os.Stat(binary); err == nil {
+		log.Printf("[Sim] Using cached build of %s", svc.Name)
+		return binary, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("build %s: %w", svc.Name, err)
+	}
+
+	log.Printf("[Sim] Building %s from %s...", svc.Name, svc.Path)
+	tmp := binary + ".tmp"
+	cmd := exec.Command("go", "build", "-o", tmp, svc.Path)
+	cmd.Dir = svc.WorkingDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("build %s failed: %w\n%s", svc.Name, err, strings.TrimSpace(string(out)))
+	}
+	if err := os.Rename(tmp, binary); err != nil {
+		return "", fmt.Errorf("build %s: %w", svc.Name, err)
+	}
+
+	log.Printf("[Sim] Built %s", svc.Name)
+	return binary, nil
+}
+
+// sourceHash hashes every non-standard-library source file that the package
+// at path depends on, together with the module's go.mod and go.sum, so that a
+// change to any of them leads to a new cache entry.
+func sourceHash(path, dir string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-deps",
+		"-f", `{{if not .Standard}}{{.Dir}}|{{join .GoFiles ","}},{{join .CgoFiles ","}},{{join .EmbedFiles ","}}{{end}}`,
+		path)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("listing sources: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		dir, names, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		for _, name := range strings.Split(names, ",") {
+			if name != "" {
+				files = append(files, filepath.Join(dir, name))
+			}
+		}
+	}
+
+	gomodCmd := exec.Command("go", "env", "GOMOD")
+	gomodCmd.Dir = dir
+	if gomod, err := gomodCmd.Output(); err == nil {
+		if mod := strings.TrimSpace(string(gomod)); mod != "" && mod != os.DevNull {
+			files = append(files, mod, filepath.Join(filepath.Dir(mod), "go.sum"))
+		}
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, name := range files {
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", name)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
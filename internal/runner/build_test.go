@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+// writeModule writes a small module whose main package depends on a second
+// package, dep, with dep's source given by depSource.
+func writeModule(t *testing.T, dir, depSource string) {
+	t.Helper()
+	files := map[string]string{
+		"go.mod":     "module example.com/svc\n\ngo 1.21\n",
+		"main.go":    "package main\n\nimport \"example.com/svc/dep\"\n\nfunc main() { println(dep.Name) }\n",
+		"dep/dep.go": depSource,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuilderCache(t *testing.T) {
+	src := t.TempDir()
+	writeModule(t, src, "package dep\n\nconst Name = \"one\"\n")
+	svc := config.Service{Name: "svc", Path: ".", WorkingDir: src}
+	b := NewBuilder(t.TempDir())
+
+	first, err := b.Build(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(first)
+	if err != nil {
+		t.Fatalf("expected a binary at %s: %v", first, err)
+	}
+
+	again, err := b.Build(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Errorf("expected a cache hit for unchanged sources, got %s then %s", first, again)
+	}
+	if cached, err := os.Stat(again); err != nil || !cached.ModTime().Equal(info.ModTime()) {
+		t.Errorf("expected the cached binary to be reused, not rebuilt")
+	}
+
+	// Changing a file the main package only depends on invalidates the cache.
+	writeModule(t, src, "package dep\n\nconst Name = \"two\"\n")
+	rebuilt, err := b.Build(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt == first {
+		t.Fatal("expected a dependency change to produce a new binary")
+	}
+	if _, err := os.Stat(rebuilt); err != nil {
+		t.Errorf("expected the rebuilt binary at %s: %v", rebuilt, err)
+	}
+}
+
+func TestBuilderFailure(t *testing.T) {
+	src := t.TempDir()
+	writeModule(t, src, "package dep\n\nconst Name = \n")
+	svc := config.Service{Name: "broken", Path: ".", WorkingDir: src}
+	cache := t.TempDir()
+
+	_, err := NewBuilder(cache).Build(svc)
+	if err == nil {
+		t.Fatal("expected the build to fail")
+	}
+	if !strings.Contains(err.Error(), "broken") || !strings.Contains(err.Error(), "dep.go") {
+		t.Errorf("expected the error to name the service and the compiler's complaint, got %v", err)
+	}
+	if entries, _ := os.ReadDir(cache); len(entries) != 0 {
+		t.Errorf("expected nothing to be cached after a failed build, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const combinedLogName = "combined"
+
+// LogFiles writes replica log lines to disk: one file per replica plus a
+// combined file holding every line. Files are rotated once they reach
+// maxSize bytes and at most maxFiles rotated copies of each are kept.
+type LogFiles struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	files    map[string]*rotatingFile
+	closed   bool
+	mu       sync.Mutex
+}
+
+func NewLogFiles(dir string, maxSize int64, maxFiles int) (*LogFiles, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create log dir: %w", err)
+	}
+	return &LogFiles{
+		dir:      dir,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		files:    make(map[string]*rotatingFile),
+	}, nil
+}
+
+func (l *LogFiles) Write(e LogEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+
+	ts := e.Time.Format(time.RFC3339Nano)
+	if err := l.file(e.Replica).write(fmt.Sprintf("%s [%s] %s\n", ts, e.Stream, e.Raw)); err != nil {
+		return err
+	}
+	return l.file(combinedLogName).write(fmt.Sprintf("%s %s %s\n", ts, e.Prefix(), e.Raw))
+}
+
+func (l *LogFiles) file(name string) *rotatingFile {
+	f, ok := l.files[name]
+	if !ok {
+		f = &rotatingFile{
+			path:     filepath.Join(l.dir, name+".log"),
+			maxSize:  l.maxSize,
+			maxFiles: l.maxFiles,
+		}
+		l.files[name] = f
+	}
+	return f
+}
+
+// Forget closes the replica's log file. Its contents are kept on disk, and a
+// later line for the same replica appends to it again.
+func (l *LogFiles) Forget(replica string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.files[replica]
+	if !ok {
+		return nil
+	}
+	delete(l.files, replica)
+	return f.close()
+}
+
+func (l *LogFiles) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+
+	var firstErr error
+	for _, f := range l.files {
+		if err := f.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// rotatingFile appends to path and, when a write would take it past
+// maxSize, shifts path.1 .. path.(maxFiles-1) up by one, moves path to
+// path.1 and starts a new file.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+func (r *rotatingFile) write(line string) error {
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.WriteString(line)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.close(); err != nil {
+		return err
+	}
+
+	if r.maxFiles <= 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
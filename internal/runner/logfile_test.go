@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogFilesRotation(t *testing.T) {
+	dir := t.TempDir()
+	files, err := NewLogFiles(dir, 128, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewLogStore(100, 100)
+	for i := 0; i < 20; i++ {
+		if err := files.Write(store.Append("svc-1", StreamStdout, "some log line")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := files.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"svc-1.log", "svc-1.log.1", "svc-1.log.2", "combined.log"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+		if info.Size() > 128 {
+			t.Errorf("%s is %d bytes, over the 128 byte limit", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "svc-1.log.3")); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 rotated files to be kept, got svc-1.log.3 (err %v)", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "combined.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[STDOUT][svc-1] some log line") {
+		t.Errorf("combined log missing replica line: %q", data)
+	}
+}
+
+func TestLogFilesForget(t *testing.T) {
+	dir := t.TempDir()
+	files, err := NewLogFiles(dir, 1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer files.Close()
+
+	store := NewLogStore(100, 100)
+	files.Write(store.Append("svc-1", StreamStdout, "before"))
+	if err := files.Forget("svc-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, open := files.files["svc-1"]; open {
+		t.Fatal("expected svc-1's file to be dropped")
+	}
+	if err := files.Forget("svc-9"); err != nil {
+		t.Errorf("forgetting an unknown replica: %v", err)
+	}
+
+	// A replica coming back under the same name appends to its old file.
+	files.Write(store.Append("svc-1", StreamStdout, "after"))
+	data, err := os.ReadFile(filepath.Join(dir, "svc-1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "before") || !strings.Contains(string(data), "after") {
+		t.Errorf("expected both lines in svc-1.log, got %q", data)
+	}
+}
@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LogLevel int
+
+// LevelUnknown is the level of lines that carry none, such as plain text
+// output. Level filters never hide them.
+const (
+	LevelUnknown LogLevel = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return ""
+	}
+}
+
+// ParseLevel understands slog's level names (including offsets such as
+// "INFO+2") and the common aliases used by other loggers.
+func ParseLevel(s string) (LogLevel, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	switch {
+	case s == "":
+		return LevelUnknown, false
+	case strings.HasPrefix(s, "DEBUG"), strings.HasPrefix(s, "TRACE"):
+		return LevelDebug, true
+	case strings.HasPrefix(s, "INFO"):
+		return LevelInfo, true
+	case strings.HasPrefix(s, "WARN"):
+		return LevelWarn, true
+	case strings.HasPrefix(s, "ERR"), strings.HasPrefix(s, "FATAL"),
+		strings.HasPrefix(s, "PANIC"), strings.HasPrefix(s, "CRIT"):
+		return LevelError, true
+	}
+	return LevelUnknown, false
+}
+
+type LogField struct {
+	Key   string
+	Value string
+}
+
+var (
+	levelKeys   = []string{"level", "lvl", "severity"}
+	messageKeys = []string{"msg", "message"}
+	timeKeys    = []string{"time", "ts", "timestamp"}
+)
+
+// parseLogLine fills in the structured parts of e from a JSON log line, as
+// written by slog's JSON handler. Anything that isn't a JSON object is left
+// as plain text.
+func parseLogLine(e *LogEntry, line string) {
+	e.Message = line
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(trimmed), &obj); err != nil {
+		return
+	}
+
+	e.Structured = true
+	if v, ok := takeString(obj, levelKeys); ok {
+		e.Level, _ = ParseLevel(v)
+	}
+	if v, ok := takeString(obj, messageKeys); ok {
+		e.Message = v
+	} else {
+		e.Message = ""
+	}
+	if v, ok := takeString(obj, timeKeys); ok {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			e.LoggedAt = t
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.Fields = append(e.Fields, LogField{Key: k, Value: formatField(obj[k])})
+	}
+}
+
+// takeString removes and returns the first of keys present in obj.
+func takeString(obj map[string]any, keys []string) (string, bool) {
+	for _, k := range keys {
+		v, ok := obj[k]
+		if !ok {
+			continue
+		}
+		delete(obj, k)
+		if s, ok := v.(string); ok {
+			return s, true
+		}
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+func formatField(v any) string {
+	switch v := v.(type) {
+	case string:
+		if strings.ContainsAny(v, " \t\"=") {
+			return strconv.Quote(v)
+		}
+		return v
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
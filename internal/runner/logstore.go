@@ -0,0 +1,282 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Stream string
+
+const (
+	StreamStdout Stream = "STDOUT"
+	StreamStderr Stream = "STDERR"
+)
+
+const (
+	DefaultLogsPerReplica = 5000
+	DefaultLogsTotal      = 100000
+)
+
+// LogEntry is one line of replica output. For JSON lines Structured is set
+// and Level, Message, LoggedAt and Fields hold the parsed parts; Raw always
+// holds the line as the replica wrote it.
+type LogEntry struct {
+	Seq     uint64
+	Time    time.Time
+	Replica string
+	Stream  Stream
+	Raw     string
+
+	Structured bool
+	Level      LogLevel
+	Message    string
+	LoggedAt   time.Time
+	Fields     []LogField
+}
+
+// Text renders the entry without its stream and replica prefix.
+func (e LogEntry) Text() string {
+	if !e.Structured {
+		return e.Message
+	}
+	var sb strings.Builder
+	if e.Level != LevelUnknown {
+		sb.WriteString(e.Level.String())
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(e.Message)
+	for _, f := range e.Fields {
+		sb.WriteString(" " + f.Key + "=" + f.Value)
+	}
+	return sb.String()
+}
+
+// Prefix is the stream and replica tag shown in front of every line.
+func (e LogEntry) Prefix() string {
+	return fmt.Sprintf("[%s][%s]", e.Stream, e.Replica)
+}
+
+// Line renders the entry the way it is shown in the log view.
+func (e LogEntry) Line() string {
+	return e.Prefix() + " " + e.Text()
+}
+
+// LogQuery selects entries from a LogStore. Zero values match everything;
+// Limit keeps only the newest matching entries. MinLevel never hides lines
+// without a level. Pattern is matched against the rendered Line.
+type LogQuery struct {
+	Replica  string
+	Stream   Stream
+	MinLevel LogLevel
+	Pattern  *regexp.Regexp
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// Match reports whether e passes every filter in q except Limit.
+func (q LogQuery) Match(e LogEntry) bool {
+	if q.Replica != "" && e.Replica != q.Replica {
+		return false
+	}
+	return q.match(e)
+}
+
+func (q LogQuery) match(e LogEntry) bool {
+	if q.MinLevel != LevelUnknown && e.Level != LevelUnknown && e.Level < q.MinLevel {
+		return false
+	}
+	if q.Pattern != nil && !q.Pattern.MatchString(e.Line()) {
+		return false
+	}
+	if q.Stream != "" && e.Stream != q.Stream {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// LogStore keeps the most recent log lines of every replica in fixed-size
+// ring buffers: at most perReplica lines per replica and at most total lines
+// overall, evicting the oldest lines first.
+type LogStore struct {
+	rings      map[string]*logRing
+	perReplica int
+	total      int
+	size       int
+	seq        uint64
+	mu         sync.RWMutex
+}
+
+func NewLogStore(perReplica, total int) *LogStore {
+	if perReplica <= 0 {
+		perReplica = DefaultLogsPerReplica
+	}
+	if total <= 0 {
+		total = DefaultLogsTotal
+	}
+	return &LogStore{
+		rings:      make(map[string]*logRing),
+		perReplica: perReplica,
+		total:      total,
+	}
+}
+
+// Append stores a line of replica output, parsing it first if it is a JSON
+// log line.
+func (s *LogStore) Append(replica string, stream Stream, line string) LogEntry {
+	entry := LogEntry{
+		Replica: replica,
+		Stream:  stream,
+		Raw:     line,
+	}
+	parseLogLine(&entry, line)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	entry.Seq = s.seq
+	entry.Time = time.Now()
+
+	ring, ok := s.rings[replica]
+	if !ok {
+		ring = newLogRing(s.perReplica)
+		s.rings[replica] = ring
+	}
+	if !ring.push(entry) {
+		s.size++
+	}
+
+	for s.size > s.total {
+		s.evictOldest()
+	}
+	return entry
+}
+
+// evictOldest drops the globally oldest entry, which is the head of one of
+// the replica rings.
+func (s *LogStore) evictOldest() {
+	var oldest *logRing
+	for _, ring := range s.rings {
+		if ring.len() == 0 {
+			continue
+		}
+		if oldest == nil || ring.at(0).Seq < oldest.at(0).Seq {
+			oldest = ring
+		}
+	}
+	if oldest == nil {
+		return
+	}
+	oldest.popFront()
+	s.size--
+}
+
+func (s *LogStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size
+}
+
+// Query returns the matching entries in the order they were logged.
+func (s *LogStore) Query(q LogQuery) []LogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []LogEntry
+	if q.Replica != "" {
+		if ring, ok := s.rings[q.Replica]; ok {
+			out = ring.collect(q)
+		}
+	} else {
+		for _, ring := range s.rings {
+			out = append(out, ring.collect(q)...)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	}
+
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out
+}
+
+// logRing holds up to capacity entries. Its buffer grows as lines arrive
+// and only wraps around once it has reached capacity.
+type logRing struct {
+	entries  []LogEntry
+	capacity int
+	start    int
+	n        int
+}
+
+func newLogRing(capacity int) *logRing {
+	return &logRing{capacity: capacity}
+}
+
+func (r *logRing) len() int { return r.n }
+
+func (r *logRing) at(i int) LogEntry {
+	return r.entries[(r.start+i)%len(r.entries)]
+}
+
+// push appends e and reports whether the oldest entry had to be overwritten.
+func (r *logRing) push(e LogEntry) bool {
+	if r.n == len(r.entries) && r.n < r.capacity {
+		if r.n == cap(r.entries) || r.start != 0 {
+			grown := make([]LogEntry, r.n, min(max(2*r.n, 64), r.capacity))
+			for i := range grown {
+				grown[i] = r.at(i)
+			}
+			r.entries, r.start = grown, 0
+		}
+		r.entries = append(r.entries, e)
+		r.n++
+		return false
+	}
+	if r.n == len(r.entries) {
+		r.entries[r.start] = e
+		r.start = (r.start + 1) % len(r.entries)
+		return true
+	}
+	r.entries[(r.start+r.n)%len(r.entries)] = e
+	r.n++
+	return false
+}
+
+func (r *logRing) popFront() {
+	r.entries[r.start] = LogEntry{}
+	r.start = (r.start + 1) % len(r.entries)
+	r.n--
+}
+
+// collect returns the matching entries. Entries are in time order, so the
+// time range is located by binary search.
+func (r *logRing) collect(q LogQuery) []LogEntry {
+	from := 0
+	if !q.Since.IsZero() {
+		from = sort.Search(r.n, func(i int) bool { return !r.at(i).Time.Before(q.Since) })
+	}
+	to := r.n
+	if !q.Until.IsZero() {
+		to = sort.Search(r.n, func(i int) bool { return r.at(i).Time.After(q.Until) })
+	}
+
+	var out []LogEntry
+	for i := from; i < to; i++ {
+		if e := r.at(i); q.match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
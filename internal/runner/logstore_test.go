@@ -0,0 +1,160 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLogStorePerReplicaCap(t *testing.T) {
+	s := NewLogStore(3, 100)
+	for i := 0; i < 5; i++ {
+		s.Append("a-1", StreamStdout, fmt.Sprintf("line %d", i))
+	}
+	s.Append("b-1", StreamStdout, "other")
+
+	got := s.Query(LogQuery{Replica: "a-1"})
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(got))
+	}
+	if got[0].Message != "line 2" || got[2].Message != "line 4" {
+		t.Errorf("expected lines 2..4, got %q..%q", got[0].Message, got[2].Message)
+	}
+	if s.Len() != 4 {
+		t.Errorf("expected 4 stored entries, got %d", s.Len())
+	}
+}
+
+func TestLogStoreTotalCapEvictsOldest(t *testing.T) {
+	s := NewLogStore(10, 4)
+	s.Append("a-1", StreamStdout, "a0")
+	s.Append("b-1", StreamStdout, "b0")
+	s.Append("a-1", StreamStdout, "a1")
+	s.Append("b-1", StreamStdout, "b1")
+	s.Append("b-1", StreamStdout, "b2")
+	s.Append("b-1", StreamStdout, "b3")
+
+	got := s.Query(LogQuery{})
+	var msgs []string
+	for _, e := range got {
+		msgs = append(msgs, e.Message)
+	}
+	if fmt.Sprint(msgs) != "[a1 b1 b2 b3]" {
+		t.Errorf("unexpected entries after eviction: %v", msgs)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Seq <= got[i-1].Seq {
+			t.Fatalf("entries not in sequence order: %v", got)
+		}
+	}
+}
+
+func TestLogRingGrowsOnDemand(t *testing.T) {
+	r := newLogRing(100)
+	r.push(LogEntry{Seq: 0})
+	if cap(r.entries) >= r.capacity {
+		t.Fatalf("ring allocated %d slots for one entry", cap(r.entries))
+	}
+
+	// Evict from the front while growing, then wrap around once full.
+	r.popFront()
+	for seq := uint64(1); seq <= 150; seq++ {
+		r.push(LogEntry{Seq: seq})
+	}
+	if r.len() != 100 || len(r.entries) != 100 {
+		t.Fatalf("expected 100 entries in 100 slots, got %d in %d", r.len(), len(r.entries))
+	}
+	for i := 0; i < r.len(); i++ {
+		if got := r.at(i).Seq; got != uint64(51+i) {
+			t.Fatalf("entry %d has seq %d, want %d", i, got, 51+i)
+		}
+	}
+}
+
+func TestLogStoreQueryFilters(t *testing.T) {
+	s := NewLogStore(10, 100)
+	s.Append("a-1", StreamStdout, "out")
+	s.Append("a-1", StreamStderr, "err")
+	mid := time.Now()
+	time.Sleep(time.Millisecond)
+	s.Append("a-1", StreamStdout, "late")
+
+	if got := s.Query(LogQuery{Stream: StreamStderr}); len(got) != 1 || got[0].Message != "err" {
+		t.Errorf("stream filter returned %v", got)
+	}
+	if got := s.Query(LogQuery{Replica: "a-1", Since: mid}); len(got) != 1 || got[0].Message != "late" {
+		t.Errorf("since filter returned %v", got)
+	}
+	if got := s.Query(LogQuery{Until: mid}); len(got) != 2 {
+		t.Errorf("until filter returned %v", got)
+	}
+	if got := s.Query(LogQuery{Limit: 1}); len(got) != 1 || got[0].Message != "late" {
+		t.Errorf("limit returned %v", got)
+	}
+	if got := s.Query(LogQuery{Pattern: regexp.MustCompile(`^\[STDOUT\].*(out|late)`)}); len(got) != 2 {
+		t.Errorf("pattern filter returned %v", got)
+	}
+}
+
+func TestLogStoreParsesJSONLines(t *testing.T) {
+	s := NewLogStore(10, 100)
+	e := s.Append("a-1", StreamStderr, `{"time":"2024-05-01T10:00:00Z","level":"WARN","msg":"slow request","path":"/pay","ms":250}`)
+	if !e.Structured || e.Level != LevelWarn || e.Message != "slow request" {
+		t.Fatalf("unexpected parse result: %+v", e)
+	}
+	if e.LoggedAt.IsZero() {
+		t.Error("expected time to be parsed")
+	}
+	if got := e.Text(); got != "WARN slow request ms=250 path=/pay" {
+		t.Errorf("unexpected text %q", got)
+	}
+
+	plain := s.Append("a-1", StreamStdout, "not {json}")
+	if plain.Structured || plain.Level != LevelUnknown || plain.Text() != "not {json}" {
+		t.Errorf("plain line parsed as structured: %+v", plain)
+	}
+
+	got := s.Query(LogQuery{MinLevel: LevelError})
+	if len(got) != 1 || got[0].Seq != plain.Seq {
+		t.Errorf("level filter should hide the warning but keep the plain line, got %v", got)
+	}
+}
+
+func TestLogCallbackRunsUnlocked(t *testing.T) {
+	r := NewRunner()
+	release := make(chan struct{})
+	var got []string
+	r.SetLogCallback(func(e LogEntry) {
+		<-release
+		got = append(got, e.Text())
+	})
+
+	published := make(chan struct{})
+	go func() {
+		r.publishLog("a-1", StreamStdout, "blocked")
+		close(published)
+	}()
+
+	// A callback stuck on a slow consumer must not hold up the runner.
+	listed := make(chan struct{})
+	go func() {
+		r.ListReplicas()
+		close(listed)
+	}()
+	select {
+	case <-listed:
+	case <-time.After(time.Second):
+		t.Fatal("ListReplicas blocked behind the log callback")
+	}
+
+	close(release)
+	<-published
+
+	var replayed []LogEntry
+	r.SetLogFilter(LogQuery{Replica: "a-1"}, 0, func(entries []LogEntry) { replayed = entries })
+	r.publishLog("a-1", StreamStdout, "after")
+	if len(replayed) != 1 || len(got) != 2 || got[1] != "after" {
+		t.Errorf("replayed %d lines and delivered %q", len(replayed), got)
+	}
+}
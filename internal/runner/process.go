@@ -9,98 +9,247 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/registry"
 )
 
+type Runner struct {
+	CMDS        map[string]*CMDEXEC
+	logs        *LogStore
+	logFiles    *LogFiles
+	logFilter   LogQuery
+	logCallback func(LogEntry)
+	logSubs     map[int]func(LogEntry)
+	nextLogSub  int
+	registry    *registry.Registry
+	services    map[string]*serviceRun
+	builder     *Builder
+	binaries    map[string]string
+	scaleMu     sync.Mutex
+
+	// logDelivery orders calls to the log callback with replays, and
+	// logFilterGen counts filter changes so lines stored before a replay
+	// aren't delivered again after it.
+	logDelivery  sync.Mutex
+	logFilterGen atomic.Uint64
 
+	sync.RWMutex
+}
 
-type ReplicaLog struct {
-	ReplicaName string
-	Message     string
+type ReplicaState string
+
+const (
+	StateStarting  ReplicaState = "starting"
+	StateReady     ReplicaState = "ready"
+	StateBackoff   ReplicaState = "backoff"
+	StateCrashLoop ReplicaState = "crashlooping"
+	StateStopping  ReplicaState = "stopping"
+	StateStopped   ReplicaState = "stopped"
+	StateExited    ReplicaState = "exited"
+	StateUnhealthy ReplicaState = "unhealthy"
+)
+
+var ReplicaStates = []ReplicaState{
+	StateStarting,
+	StateReady,
+	StateUnhealthy,
+	StateBackoff,
+	StateCrashLoop,
+	StateStopping,
+	StateStopped,
+	StateExited,
 }
 
-type Runner struct {
-	CMDS            map[string]*CMDEXEC
-	logs            []ReplicaLog
-	isolatedReplica string
-	logCallback     func(string)
-	sync.RWMutex
+// Running reports whether the replica's process is up.
+func (s ReplicaState) Running() bool {
+	return s == StateStarting || s == StateReady
 }
 
+// Active reports whether the replica counts towards its service's desired
+// replica count.
+func (s ReplicaState) Active() bool {
+	switch s {
+	case StateStarting, StateReady, StateBackoff, StateCrashLoop:
+		return true
+	default:
+		return false
+	}
+}
+
+// CMDEXEC is the runner's record of a replica. It outlives the replica's
+// process so that stopped and crashed replicas can be listed and restarted.
 type CMDEXEC struct {
-	Cmd *exec.Cmd
+	Cmd       *exec.Cmd
+	Service   config.Service
+	Port      int
+	State     ReplicaState
+	Restarts  int // consecutive automatic restarts, used for backoff
+	StartedAt time.Time
+
+	RestartCount int
+	LastExitCode int
+	LastError    string
+	Exited       bool
+
+	cancelRestart chan struct{}
+	done          chan struct{}
 }
 
 func NewRunner() *Runner {
 	return &Runner{
-		CMDS: make(map[string]*CMDEXEC),
-		logs: make([]ReplicaLog, 0),
+		CMDS:     make(map[string]*CMDEXEC),
+		logs:     NewLogStore(DefaultLogsPerReplica, DefaultLogsTotal),
+		services: make(map[string]*serviceRun),
+		builder:  NewBuilder(DefaultBuildCacheDir()),
+		binaries: make(map[string]string),
 	}
 }
 
-func (r *Runner) AddCmd(name string, cmd *exec.Cmd) {
-	r.Lock()
-	defer r.Unlock()
-	r.CMDS[name] = &CMDEXEC{
-		Cmd: cmd,
+// BuildService compiles the service into the build cache. StartService does
+// this on demand; calling it up front surfaces build errors before any
+// replica is started. Services configured with a command need no build.
+func (r *Runner) BuildService(cfgService config.Service) error {
+	if cfgService.Command != "" {
+		r.Lock()
+		r.binaries[cfgService.Name] = cfgService.Command
+		r.Unlock()
+		return nil
+	}
+
+	r.RLock()
+	builder := r.builder
+	r.RUnlock()
+
+	binary, err := builder.Build(cfgService)
+	if err != nil {
+		return err
 	}
-}
 
-func (r *Runner) RemoveCmd(name string) {
 	r.Lock()
-	defer r.Unlock()
-	delete(r.CMDS, name)
+	r.binaries[cfgService.Name] = binary
+	r.Unlock()
+	return nil
 }
 
+func (r *Runner) StartService(ctx context.Context, cfgService config.Service) error {
+	r.RLock()
+	_, built := r.binaries[cfgService.Name]
+	r.RUnlock()
+	if !built {
+		if err := r.BuildService(cfgService); err != nil {
+			return err
+		}
+	}
 
+	r.Lock()
+	r.services[cfgService.Name] = &serviceRun{ctx: ctx, cfg: cfgService}
+	r.Unlock()
 
+	return r.Scale(cfgService.Name, cfgService.Replicas)
+}
 
+func (r *Runner) startReplica(ctx context.Context, replicaName string, cfgService config.Service, port int) error {
+	r.RLock()
+	binary, ok := r.binaries[cfgService.Name]
+	r.RUnlock()
+	if !ok {
+		return fmt.Errorf("[%s] Service %s has not been built", replicaName, cfgService.Name)
+	}
 
-func (r *Runner) StartService(ctx context.Context, cfgService config.Service) error {
-
-	for i := 0; i < cfgService.Replicas; i++ {
-		replicaName := fmt.Sprintf("%s-%d", cfgService.Name, i+1)
+	env := make(map[string]string, len(cfgService.Env)+1)
+	for k, v := range cfgService.Env {
+		env[k] = v
+	}
+	env["PORT"] = strconv.Itoa(port)
+
+	// Args may reference the replica's environment, e.g. "$PORT".
+	args := make([]string, len(cfgService.Args))
+	for i, arg := range cfgService.Args {
+		args[i] = os.Expand(arg, func(key string) string {
+			if v, ok := env[key]; ok {
+				return v
+			}
+			return os.Getenv(key)
+		})
+	}
 
-		cmd := exec.Command("go", "run", cfgService.Path)
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = cfgService.WorkingDir
 
-		cmd.Env = os.Environ()
-		for k, v := range cfgService.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-		}
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PORT=%d", cfgService.StartPort+i))
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
 
-		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-		stdout, err := cmd.StdoutPipe()
+	var matchLine func(string)
+	var logMatched chan struct{}
+	if probe := cfgService.ReadinessProbe(); probe.Type == config.ProbeLog {
+		pattern, err := regexp.Compile(probe.Pattern)
 		if err != nil {
-			return fmt.Errorf("[%s] Error creating stdout pipe: %w", replicaName, err)
+			return fmt.Errorf("[%s] Invalid readiness pattern: %w", replicaName, err)
 		}
-		stderrout, err := cmd.StderrPipe()
-		if err != nil {
-			return fmt.Errorf("[%s] Error creating stderr pipe: %w", replicaName, err)
+		logMatched = make(chan struct{})
+		var once sync.Once
+		matchLine = func(line string) {
+			if pattern.MatchString(line) {
+				once.Do(func() { close(logMatched) })
+			}
 		}
+	}
 
-		if err := cmd.Start(); err != nil {
-			return fmt.Errorf("[%s] Error starting command: %w", replicaName, err)
-		}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("[%s] Error creating stdout pipe: %w", replicaName, err)
+	}
+	stderrout, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("[%s] Error creating stderr pipe: %w", replicaName, err)
+	}
 
-		r.AddCmd(replicaName, cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("[%s] Error starting command: %w", replicaName, err)
+	}
 
-		fmt.Printf("[Sim] Starting replica %s\n", replicaName)
+	r.Lock()
+	replica, ok := r.CMDS[replicaName]
+	if !ok {
+		replica = &CMDEXEC{}
+		r.CMDS[replicaName] = replica
+	}
+	replica.Cmd = cmd
+	replica.Service = cfgService
+	replica.Port = port
+	replica.State = StateStarting
+	replica.StartedAt = time.Now()
+	replica.cancelRestart = nil
+	replica.done = make(chan struct{})
+	done := replica.done
+	r.Unlock()
 
-		go r.outputLogs(replicaName, "STDOUT", stdout)
-		go r.outputLogs(replicaName, "STDERR", stderrout)
+	log.Printf("[Sim] Starting replica %s", replicaName)
 
-		go r.waitForExit(replicaName, cmd)
-	}
+	go r.outputLogs(replicaName, StreamStdout, stdout, matchLine)
+	go r.outputLogs(replicaName, StreamStderr, stderrout, matchLine)
+
+	go r.waitForExit(ctx, replicaName, cmd, done)
+	go r.awaitReady(ctx, replicaName, cmd, cfgService, port, done, logMatched)
 
 	return nil
 }
 
-func (r *Runner) waitForExit(replicaName string, cmd *exec.Cmd) {
+func (r *Runner) waitForExit(ctx context.Context, replicaName string, cmd *exec.Cmd, done chan struct{}) {
+	defer close(done)
+
 	err := cmd.Wait()
 	if err != nil {
 		log.Printf("[Sim] Replica %s exited with error: %s", replicaName, err)
@@ -108,126 +257,502 @@ func (r *Runner) waitForExit(replicaName string, cmd *exec.Cmd) {
 		log.Printf("[Sim] Replica %s exited successfully", replicaName)
 	}
 
-	r.RemoveCmd(replicaName)
+	r.Lock()
+	replica, ok := r.CMDS[replicaName]
+	if !ok || replica.Cmd != cmd {
+		// Forgotten or already replaced by a newer process.
+		r.Unlock()
+		return
+	}
+	replica.Exited = true
+	replica.LastExitCode = cmd.ProcessState.ExitCode()
+	replica.LastError = ""
+	if replica.State == StateStopping {
+		// StopReplica marks the replica stopped once it sees the exit and
+		// keeps the record so it can be restarted; no restart policy applies.
+		// The exit was asked for, so the signal isn't reported as an error.
+		r.Unlock()
+		return
+	}
+	if err != nil {
+		replica.LastError = err.Error()
+	}
+	if r.registry != nil {
+		r.registry.Deregister(replica.Service.Name, replicaName)
+	}
+
+	svc := replica.Service
+	if ctx.Err() != nil || !shouldRestart(svc.RestartPolicy, err) {
+		replica.State = StateExited
+		r.Unlock()
+		return
+	}
+
+	// A replica that stayed up longer than the maximum backoff is considered
+	// healthy again, so earlier crashes no longer count against it.
+	if time.Since(replica.StartedAt) >= svc.MaxRestartBackoff {
+		replica.Restarts = 0
+	}
+
+	if replica.Restarts >= svc.RetryLimit() {
+		replica.State = StateCrashLoop
+		r.Unlock()
+		log.Printf("[Sim] Replica %s is crash looping after %d restarts, giving up", replicaName, svc.RetryLimit())
+		return
+	}
+
+	delay := restartDelay(svc, replica.Restarts)
+	replica.Restarts++
+	replica.State = StateBackoff
+	cancel := make(chan struct{})
+	replica.cancelRestart = cancel
+	attempt := replica.Restarts
+	r.Unlock()
+
+	log.Printf("[Sim] Restarting replica %s in %s (attempt %d/%d)", replicaName, delay, attempt, svc.RetryLimit())
+	go r.restartAfter(ctx, replicaName, delay, cancel)
+}
+
+func (r *Runner) restartAfter(ctx context.Context, replicaName string, delay time.Duration, cancel <-chan struct{}) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-cancel:
+		return
+	case <-timer.C:
+	}
+
+	r.RLock()
+	replica, ok := r.CMDS[replicaName]
+	var svc config.Service
+	var port int
+	if ok {
+		svc, port = replica.Service, replica.Port
+		// Stopped or restarted by hand while we were waiting.
+		ok = replica.State == StateBackoff && replica.cancelRestart == cancel
+	}
+	r.RUnlock()
+	if !ok {
+		return
+	}
+
+	if err := r.startReplica(ctx, replicaName, svc, port); err != nil {
+		log.Printf("[Sim] Failed to restart replica %s: %s", replicaName, err)
+		r.Lock()
+		if replica, ok := r.CMDS[replicaName]; ok {
+			replica.State = StateCrashLoop
+			replica.LastError = err.Error()
+		}
+		r.Unlock()
+		return
+	}
+
+	r.Lock()
+	if replica, ok := r.CMDS[replicaName]; ok {
+		replica.RestartCount++
+	}
+	r.Unlock()
+}
+
+func shouldRestart(policy string, exitErr error) bool {
+	switch policy {
+	case config.RestartAlways:
+		return true
+	case config.RestartOnFailure:
+		return exitErr != nil
+	default:
+		return false
+	}
+}
+
+func restartDelay(svc config.Service, restarts int) time.Duration {
+	delay := svc.RestartBackoff
+	for i := 0; i < restarts && delay < svc.MaxRestartBackoff; i++ {
+		delay *= 2
+	}
+	if delay > svc.MaxRestartBackoff {
+		delay = svc.MaxRestartBackoff
+	}
+	return delay
 }
 
-func (r *Runner) outputLogs(replicaName, logType string, pipe io.ReadCloser) {
+func (r *Runner) outputLogs(replicaName string, stream Stream, pipe io.ReadCloser, matchLine func(string)) {
 	scanner := bufio.NewScanner(pipe)
 	scanner.Split(bufio.ScanLines)
 	for scanner.Scan() {
 		m := scanner.Text()
-		output := fmt.Sprintf("[%s][%s] %s", logType, replicaName, m)
-
-		r.addLog(replicaName, output)
-		r.UpdateLatestLog()
+		if matchLine != nil {
+			matchLine(m)
+		}
+		r.publishLog(replicaName, stream, m)
 	}
 }
 
-
-func (r *Runner) UpdateLatestLog() {
+// publishLog stores a line, writes it to the log files and hands it to the
+// subscribers and, if it matches the log filter, the log callback. Everything
+// but storing runs after the runner is unlocked so that disk I/O or a slow
+// consumer can't hold up the runner. The callback is skipped if the filter
+// changed in between: the line was then stored before SetLogFilter read the
+// history, so the replay already covered it.
+func (r *Runner) publishLog(replicaName string, stream Stream, line string) {
 	r.RLock()
-	defer r.RUnlock()
+	entry := r.logs.Append(replicaName, stream, line)
+	files := r.logFiles
+	callback := r.logCallback
+	if !r.logFilter.Match(entry) {
+		callback = nil
+	}
+	gen := r.logFilterGen.Load()
+	subs := make([]func(LogEntry), 0, len(r.logSubs))
+	for _, fn := range r.logSubs {
+		subs = append(subs, fn)
+	}
+	r.RUnlock()
 
-	if len(r.logs) == 0 {
+	if files != nil {
+		if err := files.Write(entry); err != nil {
+			log.Printf("[Sim] Failed to write log file for %s: %v", replicaName, err)
+		}
+	}
+	for _, fn := range subs {
+		fn(entry)
+	}
+	if callback == nil {
 		return
 	}
+	r.logDelivery.Lock()
+	defer r.logDelivery.Unlock()
+	if r.logFilterGen.Load() == gen {
+		callback(entry)
+	}
+}
 
-	latestLog := r.logs[len(r.logs)-1]
+// GetLogs returns stored log entries matching q, oldest first.
+func (r *Runner) GetLogs(q LogQuery) []LogEntry {
+	return r.logStore().Query(q)
+}
 
-	if r.isolatedReplica == "" || r.isolatedReplica == latestLog.ReplicaName {
-		if r.logCallback != nil {
-			r.logCallback(latestLog.Message)
-		}
+// SetLogDir makes the runner also write replica logs to files in dir, see
+// LogFiles.
+func (r *Runner) SetLogDir(dir string, maxSize int64, maxFiles int) error {
+	files, err := NewLogFiles(dir, maxSize, maxFiles)
+	if err != nil {
+		return err
 	}
-}
-func (r *Runner) addLog(replicaName, logLine string) {
 	r.Lock()
 	defer r.Unlock()
-	r.logs = append(r.logs, ReplicaLog{
-		ReplicaName: replicaName,
-		Message:     logLine,
-	})
+	r.logFiles = files
+	return nil
 }
 
-func (r *Runner) GetLogs() []ReplicaLog {
+// CloseLogs flushes and closes any log files. Lines logged afterwards are
+// only kept in memory.
+func (r *Runner) CloseLogs() error {
 	r.RLock()
 	defer r.RUnlock()
-	var filtered []ReplicaLog
-	for _, l := range r.logs {
-		if l.ReplicaName == r.isolatedReplica {
-			filtered = append(filtered, l)
-		}
+	if r.logFiles == nil {
+		return nil
 	}
-	return filtered
+	return r.logFiles.Close()
 }
 
+func (r *Runner) logStore() *LogStore {
+	r.RLock()
+	defer r.RUnlock()
+	return r.logs
+}
 
+// SetLogLimits replaces the log store with one holding at most perReplica
+// lines per replica and total lines overall. Lines logged so far are dropped.
+func (r *Runner) SetLogLimits(perReplica, total int) {
+	r.Lock()
+	defer r.Unlock()
+	r.logs = NewLogStore(perReplica, total)
+}
 
+// StopReplica stops a replica on purpose; its restart policy is not applied.
+// The service's stop signal is sent first and the replica gets stop_timeout
+// to exit before it is killed with SIGKILL. The replica stays listed as
+// stopped so it can be restarted later.
 func (r *Runner) StopReplica(replicaName string) error {
 	r.Lock()
 	replica, ok := r.CMDS[replicaName]
-	if ok {
-		delete(r.CMDS, replicaName)
+	if !ok {
+		r.Unlock()
+		return fmt.Errorf("replica %s not found", replicaName)
+	}
+
+	switch replica.State {
+	case StateStopping:
+		r.Unlock()
+		return fmt.Errorf("replica %s is already stopping", replicaName)
+	case StateStopped, StateExited:
+		r.Unlock()
+		return fmt.Errorf("replica %s is not running", replicaName)
+	case StateBackoff, StateCrashLoop:
+		if replica.cancelRestart != nil {
+			close(replica.cancelRestart)
+			replica.cancelRestart = nil
+		}
+		replica.State = StateStopped
+		r.Unlock()
+		log.Printf("[Sim] Stopped replica %s", replicaName)
+		return nil
 	}
+
+	if r.registry != nil {
+		r.registry.Deregister(replica.Service.Name, replicaName)
+	}
+	replica.State = StateStopping
+	cmd, done, svc := replica.Cmd, replica.done, replica.Service
 	r.Unlock()
 
+	defer func() {
+		r.Lock()
+		if replica.Cmd == cmd {
+			replica.State = StateStopped
+		}
+		r.Unlock()
+	}()
+
+	sig, err := config.ParseSignal(svc.StopSignal)
+	if err != nil {
+		sig = syscall.SIGTERM
+	}
+	timeout := svc.StopTimeout
+	if timeout <= 0 {
+		timeout = config.DefaultStopTimeout
+	}
+
+	if err := signalReplica(cmd, sig); err != nil {
+		log.Printf("[Sim] Error sending %s to replica %s: %s", config.SignalName(sig), replicaName, err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		log.Printf("[Sim] Stopped replica %s", replicaName)
+		return nil
+	case <-timer.C:
+	}
+
+	log.Printf("[Sim] Replica %s did not stop within %s, sending SIGKILL", replicaName, timeout)
+	if err := signalReplica(cmd, syscall.SIGKILL); err != nil {
+		log.Printf("[Sim] Error killing replica %s: %s", replicaName, err)
+		return err
+	}
+	<-done
+
+	log.Printf("[Sim] Killed replica %s", replicaName)
+	return nil
+}
+
+// KillReplica simulates a failure by sending sig to the replica. The replica
+// stays supervised, so the service's restart policy decides whether it comes
+// back.
+func (r *Runner) KillReplica(replicaName string, sig syscall.Signal) error {
+	r.RLock()
+	replica, ok := r.CMDS[replicaName]
+	var cmd *exec.Cmd
+	running := false
+	if ok {
+		cmd, running = replica.Cmd, replica.State.Running()
+	}
+	r.RUnlock()
+
 	if !ok {
 		return fmt.Errorf("replica %s not found", replicaName)
 	}
+	if !running {
+		return fmt.Errorf("replica %s is not running", replicaName)
+	}
+
+	if err := signalReplica(cmd, sig); err != nil {
+		log.Printf("[Sim] Error sending %s to replica %s: %s", config.SignalName(sig), replicaName, err)
+		return err
+	}
 
-	pgid, err := syscall.Getpgid(replica.Cmd.Process.Pid)
+	log.Printf("[Sim] Sent %s to replica %s", config.SignalName(sig), replicaName)
+	return nil
+}
+
+func signalReplica(cmd *exec.Cmd, sig syscall.Signal) error {
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
 	if err == nil {
-		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
-			log.Printf("[Sim] Error killing replica group %s: %s", replicaName, err)
-		}
-	} else {
-		if err := replica.Cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
-			log.Printf("[Sim] Error killing replica %s: %s", replicaName, err)
+		if err := syscall.Kill(-pgid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
 			return err
 		}
+		return nil
+	}
+	if err := cmd.Process.Signal(sig); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
 	}
-	
-	log.Printf("[Sim] Stopped replica %s", replicaName)
 	return nil
 }
 
-func (r *Runner) ListReplicas() []string {
+// LogFilter returns the filter lines must pass to reach the log callback.
+func (r *Runner) LogFilter() LogQuery {
 	r.RLock()
 	defer r.RUnlock()
-	replicas := make([]string, 0, len(r.CMDS))
-	for name := range r.CMDS {
-		replicas = append(replicas, name)
-	}
-	return replicas
+	return r.logFilter
 }
 
-func (r *Runner) GetIsolatedReplica() string {
-	r.RLock()
-	defer r.RUnlock()
-	return r.isolatedReplica
+// SetLogFilter changes which lines reach the log callback and passes the
+// newest limit stored lines that match the new filter to replay. replay runs
+// before any later line reaches the log callback, so the caller can redraw
+// its log view without losing or duplicating lines. Like the log callback,
+// it runs with the runner unlocked.
+func (r *Runner) SetLogFilter(filter LogQuery, limit int, replay func([]LogEntry)) {
+	r.logDelivery.Lock()
+	defer r.logDelivery.Unlock()
+
+	r.Lock()
+	filter.Limit = 0
+	r.logFilter = filter
+	r.logFilterGen.Add(1)
+	filter.Limit = limit
+	entries := r.logs.Query(filter)
+	r.Unlock()
+
+	replay(entries)
 }
 
-func (r *Runner) SetIsolatedReplica(name string) {
+// SubscribeLogs calls fn with every new log line, regardless of the log
+// filter, until the returned function is called. fn runs on the replica's
+// output goroutine, so it must not block.
+func (r *Runner) SubscribeLogs(fn func(LogEntry)) (unsubscribe func()) {
 	r.Lock()
 	defer r.Unlock()
-	r.isolatedReplica = name
+	if r.logSubs == nil {
+		r.logSubs = make(map[int]func(LogEntry))
+	}
+	id := r.nextLogSub
+	r.nextLogSub++
+	r.logSubs[id] = fn
+	return func() {
+		r.Lock()
+		defer r.Unlock()
+		delete(r.logSubs, id)
+	}
 }
 
-func (r *Runner) SetLogCallback(cb func(string)) {
+// followLogBuffer is how many lines a FollowLogs caller may fall behind
+// before new lines are dropped for it.
+const followLogBuffer = 256
+
+// FollowLogs calls fn with the stored lines matching q, oldest first, and
+// then with every matching line logged afterwards, until ctx is done or fn
+// returns an error, which is returned. It subscribes before reading the
+// history, so no line falls in between and none is passed twice. fn runs on
+// the caller's goroutine; lines are dropped for callers that can't keep up.
+func (r *Runner) FollowLogs(ctx context.Context, q LogQuery, fn func(LogEntry) error) error {
+	live := make(chan LogEntry, followLogBuffer)
+	unsubscribe := r.SubscribeLogs(func(e LogEntry) {
+		if !q.Match(e) {
+			return
+		}
+		select {
+		case live <- e:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	var last uint64
+	for _, e := range r.GetLogs(q) {
+		if err := fn(e); err != nil {
+			return err
+		}
+		last = e.Seq
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-live:
+			if e.Seq <= last {
+				continue
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *Runner) SetLogCallback(cb func(LogEntry)) {
 	r.Lock()
 	defer r.Unlock()
 	r.logCallback = cb
 }
 
+// SetRegistry makes the runner publish replica endpoints to reg as replicas
+// start, stop and exit.
+func (r *Runner) SetRegistry(reg *registry.Registry) {
+	r.Lock()
+	defer r.Unlock()
+	r.registry = reg
+}
+
+func (r *Runner) SetBuildCacheDir(dir string) {
+	r.Lock()
+	defer r.Unlock()
+	r.builder = NewBuilder(dir)
+}
+
+// ShutdownAll stops every replica. Services are stopped in reverse
+// depends_on order so that dependents go down before what they depend on.
 func (r *Runner) ShutdownAll() {
 	r.RLock()
-	replicas := make([]string, 0, len(r.CMDS))
-	for name := range r.CMDS {
-		replicas = append(replicas, name)
+	services := make(map[string]config.Service, len(r.services))
+	for name, svc := range r.services {
+		services[name] = svc.cfg
 	}
 	r.RUnlock()
 
-	for _, name := range replicas {
-		r.StopReplica(name)
+	// Validated configs have no cycles; without tiers everything is stopped
+	// at once below.
+	tiers, _ := config.DependencyTiers(services)
+	for i := len(tiers) - 1; i >= 0; i-- {
+		r.stopReplicas(r.activeReplicas(func(svc string) bool {
+			return slices.Contains(tiers[i], svc)
+		}))
+	}
+
+	// Anything not covered by the tiers, e.g. replicas of services that were
+	// never started through StartService.
+	r.stopReplicas(r.activeReplicas(func(string) bool { return true }))
+}
+
+func (r *Runner) activeReplicas(match func(service string) bool) []string {
+	r.RLock()
+	defer r.RUnlock()
+	replicas := make([]string, 0, len(r.CMDS))
+	for name, replica := range r.CMDS {
+		if replica.State.Active() && match(replica.Service.Name) {
+			replicas = append(replicas, name)
+		}
+	}
+	return replicas
+}
+
+// stopReplicas stops the named replicas concurrently so that slow graceful
+// shutdowns don't add up.
+func (r *Runner) stopReplicas(names []string) {
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := r.StopReplica(name); err != nil {
+				log.Printf("[Sim] Error stopping replica %s: %s", name, err)
+			}
+		}(name)
 	}
+	wg.Wait()
 }
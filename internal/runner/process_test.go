@@ -0,0 +1,254 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner/runnertest"
+)
+
+func TestShouldRestart(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+	tests := []struct {
+		policy string
+		err    error
+		want   bool
+	}{
+		{config.RestartAlways, nil, true},
+		{config.RestartAlways, exitErr, true},
+		{config.RestartOnFailure, nil, false},
+		{config.RestartOnFailure, exitErr, true},
+		{config.RestartNever, nil, false},
+		{config.RestartNever, exitErr, false},
+		{"", exitErr, false},
+	}
+	for _, tt := range tests {
+		if got := shouldRestart(tt.policy, tt.err); got != tt.want {
+			t.Errorf("shouldRestart(%q, %v) = %v, want %v", tt.policy, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	svc := config.Service{RestartBackoff: time.Second, MaxRestartBackoff: 5 * time.Second}
+	tests := []struct {
+		restarts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 5 * time.Second},
+		{10, 5 * time.Second},
+		{1000, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := restartDelay(svc, tt.restarts); got != tt.want {
+			t.Errorf("restartDelay after %d restarts = %s, want %s", tt.restarts, got, tt.want)
+		}
+	}
+
+	// A backoff above the cap is clamped straight away.
+	svc.RestartBackoff = time.Minute
+	if got := restartDelay(svc, 0); got != svc.MaxRestartBackoff {
+		t.Errorf("expected the delay to be capped at %s, got %s", svc.MaxRestartBackoff, got)
+	}
+}
+
+func TestCrashLoop(t *testing.T) {
+	retries := 2
+	svc := runnertest.ShellService("crasher", "exit 3")
+	svc.RestartPolicy = config.RestartOnFailure
+	svc.MaxRetries = &retries
+	svc.RestartBackoff = 10 * time.Millisecond
+	svc.MaxRestartBackoff = time.Second
+
+	r := startTestService(t, svc)
+	// The restart counter is bumped once a restarted process has started, so
+	// the last restart may still be counted after the state changes.
+	st := waitForReplica(t, r, "crasher-1", func(st ReplicaStatus) bool {
+		return st.State == StateCrashLoop && st.Restarts == retries
+	})
+	if !st.Exited || st.LastExitCode != 3 {
+		t.Errorf("expected exit code 3 to be recorded, got exited=%v code=%d", st.Exited, st.LastExitCode)
+	}
+}
+
+func TestStopReplicaGraceful(t *testing.T) {
+	// Exiting 0 shows the trap ran; SIGUSR1 would kill sh otherwise.
+	svc := runnertest.ShellService("polite", `trap 'exit 0' USR1; echo ready; while true; do sleep 0.05; done`)
+	svc.StopSignal = "SIGUSR1"
+	svc.StopTimeout = 5 * time.Second
+	svc.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "^ready$"}
+	r := startTestService(t, svc)
+	waitForState(t, r, "polite-1", StateReady)
+
+	start := time.Now()
+	if err := r.StopReplica("polite-1"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= svc.StopTimeout {
+		t.Errorf("expected the stop signal to end the replica, took %s", elapsed)
+	}
+	st, _ := replicaStatus(r, "polite-1")
+	if st.State != StateStopped || st.LastExitCode != 0 || st.LastError != "" {
+		t.Errorf("expected a clean stop, got %+v", st)
+	}
+}
+
+func TestStopReplicaEscalatesToKill(t *testing.T) {
+	// Ignored signals stay ignored in the sleeps the loop starts, so nothing
+	// in the process group reacts to SIGTERM.
+	svc := runnertest.ShellService("stubborn", `trap '' TERM; echo ready; while true; do sleep 0.05; done`)
+	svc.StopTimeout = 200 * time.Millisecond
+	svc.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "^ready$"}
+	r := startTestService(t, svc)
+	waitForState(t, r, "stubborn-1", StateReady)
+
+	start := time.Now()
+	if err := r.StopReplica("stubborn-1"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < svc.StopTimeout {
+		t.Errorf("expected the replica to get stop_timeout before SIGKILL, took %s", elapsed)
+	}
+	st, _ := replicaStatus(r, "stubborn-1")
+	if st.State != StateStopped {
+		t.Errorf("expected the killed replica to be stopped, got %s", st.State)
+	}
+	if st.LastError != "" {
+		t.Errorf("expected no error for an intentional stop, got %q", st.LastError)
+	}
+}
+
+func TestKillReplicaRestartPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy string
+		state  ReplicaState
+	}{
+		{config.RestartOnFailure, StateStarting},
+		{config.RestartNever, StateExited},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			svc := runnertest.ShellService("victim", "exec sleep 60")
+			svc.RestartPolicy = tt.policy
+			svc.RestartBackoff = 10 * time.Millisecond
+			svc.MaxRestartBackoff = time.Second
+			r := startTestService(t, svc)
+
+			if err := r.KillReplica("victim-1", syscall.SIGKILL); err != nil {
+				t.Fatal(err)
+			}
+			st := waitForReplica(t, r, "victim-1", func(st ReplicaStatus) bool {
+				return st.Exited && st.State == tt.state
+			})
+			if st.LastError != "signal: killed" {
+				t.Errorf("expected the kill to be reported as an error, got %q", st.LastError)
+			}
+		})
+	}
+
+	r := NewRunner()
+	if err := r.KillReplica("missing-1", syscall.SIGKILL); err == nil {
+		t.Error("expected killing an unknown replica to fail")
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	r := NewRunner()
+	r.publishLog("a-1", StreamStdout, "old")
+	r.publishLog("b-1", StreamStdout, "other")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan string, 10)
+	done := make(chan error)
+	go func() {
+		done <- r.FollowLogs(ctx, LogQuery{Replica: "a-1"}, func(e LogEntry) error {
+			lines <- e.Message
+			return nil
+		})
+	}()
+
+	next := func() string {
+		t.Helper()
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(time.Second):
+			t.Fatal("no line followed")
+			return ""
+		}
+	}
+	if got := next(); got != "old" {
+		t.Fatalf("expected the history first, got %q", got)
+	}
+	r.publishLog("b-1", StreamStdout, "other")
+	r.publishLog("a-1", StreamStdout, "new")
+	if got := next(); got != "new" {
+		t.Fatalf("expected the new line, got %q", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected FollowLogs to end cleanly, got %v", err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("unexpected extra line %q", <-lines)
+	}
+
+	// An error from fn ends following and is passed on.
+	stop := errors.New("stop")
+	err := r.FollowLogs(context.Background(), LogQuery{Replica: "a-1"}, func(LogEntry) error { return stop })
+	if err != stop {
+		t.Errorf("expected fn's error back, got %v", err)
+	}
+}
+
+func startTestService(t *testing.T, svc config.Service) *Runner {
+	t.Helper()
+	r := NewRunner()
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := r.StartService(ctx, svc); err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.ShutdownAll()
+		cancel()
+	})
+	return r
+}
+
+func replicaStatus(r *Runner, name string) (ReplicaStatus, bool) {
+	for _, st := range r.ListReplicas() {
+		if st.Name == name {
+			return st, true
+		}
+	}
+	return ReplicaStatus{}, false
+}
+
+// waitForReplica polls the replica's status until done accepts it.
+func waitForReplica(t *testing.T, r *Runner, name string, done func(ReplicaStatus) bool) ReplicaStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st, ok := replicaStatus(r, name)
+		if ok && done(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gave up waiting for %s, last status %+v", name, st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitForState(t *testing.T, r *Runner, name string, state ReplicaState) ReplicaStatus {
+	t.Helper()
+	return waitForReplica(t, r, name, func(st ReplicaStatus) bool { return st.State == state })
+}
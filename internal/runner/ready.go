@@ -0,0 +1,182 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+const (
+	readyPollInterval   = 200 * time.Millisecond
+	probeAttemptTimeout = time.Second
+)
+
+// awaitReady probes a freshly started replica until it passes the service's
+// readiness probe, then marks it ready and publishes it to the registry so
+// the load balancer starts routing to it. logMatched is closed by the log
+// readers once a line matches a log probe's pattern. The probe's timeout
+// only logs a warning: probing goes on until the replica is ready or exits,
+// so a slow replica still joins the load balancer. Callers that need a bound
+// use WaitReady or WaitServiceReady.
+func (r *Runner) awaitReady(ctx context.Context, replicaName string, cmd *exec.Cmd, svc config.Service, port int, done, logMatched <-chan struct{}) {
+	probe := svc.ReadinessProbe()
+
+	ticker := time.NewTicker(probe.Interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(probe.Timeout)
+	defer deadline.Stop()
+
+	for {
+		var ready bool
+		if probe.Type == config.ProbeLog {
+			select {
+			case <-logMatched:
+				ready = true
+			default:
+			}
+		} else {
+			ready = probeReplica(ctx, port, probe) == nil
+		}
+		if ready {
+			r.markReady(replicaName, cmd, port)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-deadline.C:
+			log.Printf("[Sim] Replica %s is still not ready after %s (%s probe)", replicaName, probe.Timeout, probe.Type)
+		case <-logMatched:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) markReady(replicaName string, cmd *exec.Cmd, port int) {
+	r.Lock()
+	replica, ok := r.CMDS[replicaName]
+	if !ok || replica.Cmd != cmd || replica.State != StateStarting {
+		r.Unlock()
+		return
+	}
+	replica.State = StateReady
+	if r.registry != nil {
+		if err := r.registry.Register(replica.Service.Name, replicaName, port); err != nil {
+			log.Printf("[Sim] Failed to register replica %s: %s", replicaName, err)
+		}
+	}
+	r.Unlock()
+
+	log.Printf("[Sim] Replica %s is ready on port %d", replicaName, port)
+}
+
+func probeReplica(ctx context.Context, port int, probe config.Readiness) error {
+	ctx, cancel := context.WithTimeout(ctx, probeAttemptTimeout)
+	defer cancel()
+
+	addr := fmt.Sprintf("localhost:%d", port)
+	if probe.Type == config.ProbeTCP {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+probe.Path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %s", probe.Path, resp.Status)
+	}
+	return nil
+}
+
+// WaitReady blocks until the replica has passed its readiness probe, it
+// stops running, or timeout elapses.
+func (r *Runner) WaitReady(ctx context.Context, replicaName string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.RLock()
+		replica, ok := r.CMDS[replicaName]
+		var state ReplicaState
+		if ok {
+			state = replica.State
+		}
+		r.RUnlock()
+
+		if !ok {
+			return fmt.Errorf("replica %s not found", replicaName)
+		}
+		if state == StateReady {
+			return nil
+		}
+		if state != StateStarting {
+			return fmt.Errorf("replica %s is %s", replicaName, state)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("replica %s not ready within %s", replicaName, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitServiceReady blocks until at least one replica of the service is
+// ready. A ready replica is in the registry, so once the load balancer is
+// running it routes to it.
+func (r *Runner) WaitServiceReady(ctx context.Context, serviceName string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.RLock()
+		_, started := r.services[serviceName]
+		ready := false
+		for _, replica := range r.CMDS {
+			if replica.Service.Name == serviceName && replica.State == StateReady {
+				ready = true
+				break
+			}
+		}
+		r.RUnlock()
+
+		if !started {
+			return fmt.Errorf("service %s is not started", serviceName)
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service %s has no ready replica after %s", serviceName, timeout)
+		case <-ticker.C:
+		}
+	}
+}
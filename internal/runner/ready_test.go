@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner/runnertest"
+)
+
+func TestProbeHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ready":
+			w.WriteHeader(http.StatusNoContent)
+		case "/moved":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+
+	for path, wantReady := range map[string]bool{
+		"/ready":    true,
+		"/moved":    true,
+		"/starting": false,
+	} {
+		err := probeReplica(context.Background(), port, config.Readiness{Type: config.ProbeHTTP, Path: path})
+		if ready := err == nil; ready != wantReady {
+			t.Errorf("probing %s: expected ready=%v, got error %v", path, wantReady, err)
+		}
+	}
+
+	srv.Close()
+	if err := probeReplica(context.Background(), port, config.Readiness{Type: config.ProbeHTTP, Path: "/ready"}); err == nil {
+		t.Error("expected probing a closed server to fail")
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	probe := config.Readiness{Type: config.ProbeTCP}
+	if err := probeReplica(context.Background(), port, probe); err != nil {
+		t.Errorf("expected the probe to connect: %v", err)
+	}
+	ln.Close()
+	if err := probeReplica(context.Background(), port, probe); err == nil {
+		t.Error("expected the probe to fail once nothing listens")
+	}
+}
+
+func TestAwaitReadyKeepsProbingAfterTimeout(t *testing.T) {
+	svc := runnertest.ShellService("slow", "sleep 0.5; echo ready; exec sleep 60")
+	svc.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "^ready$", Timeout: 100 * time.Millisecond}
+	r := startTestService(t, svc)
+
+	err := r.WaitReady(context.Background(), "slow-1", 200*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected slow-1 not to be ready yet")
+	}
+	if st, _ := replicaStatus(r, "slow-1"); st.State != StateStarting {
+		t.Errorf("expected slow-1 to still be starting past its probe timeout, got %s", st.State)
+	}
+	waitForState(t, r, "slow-1", StateReady)
+}
+
+func TestWaitReadyExitedReplica(t *testing.T) {
+	svc := runnertest.ShellService("quitter", "exit 0")
+	r := startTestService(t, svc)
+
+	st := waitForState(t, r, "quitter-1", StateExited)
+	if !st.Exited {
+		t.Errorf("expected the exit to be recorded, got %+v", st)
+	}
+	if err := r.WaitReady(context.Background(), "quitter-1", time.Second); err == nil {
+		t.Error("expected WaitReady to fail for an exited replica")
+	}
+}
@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// RestartReplica stops the replica if it is running and starts it again on
+// the same port with the same environment. The restart counter is reset.
+func (r *Runner) RestartReplica(replicaName string) error {
+	r.RLock()
+	replica, ok := r.CMDS[replicaName]
+	var state ReplicaState
+	if ok {
+		state = replica.State
+	}
+	r.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("replica %s not found", replicaName)
+	}
+	if state.Running() {
+		if err := r.StopReplica(replicaName); err != nil {
+			return err
+		}
+	}
+
+	if err := r.reviveReplica(replicaName); err != nil {
+		return err
+	}
+	log.Printf("[Sim] Restarted replica %s", replicaName)
+	return nil
+}
+
+// RestartService restarts every replica of the service concurrently.
+func (r *Runner) RestartService(serviceName string) error {
+	if !r.HasService(serviceName) {
+		return fmt.Errorf("service %s not found", serviceName)
+	}
+
+	active, inactive := r.serviceReplicas(serviceName)
+	names := append(active, inactive...)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(names))
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			errs[i] = r.RestartReplica(name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("[Sim] Failed to restart replica %s: %s", names[i], err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("service %s: %d of %d replicas failed to restart", serviceName, failed, len(names))
+	}
+	return nil
+}
+
+func (r *Runner) HasService(serviceName string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	_, ok := r.services[serviceName]
+	return ok
+}
+
+// reviveReplica starts a replica that is not currently running, reusing its
+// recorded service config and, if nothing else took it in the meantime, its
+// port. Otherwise it gets a new port the way a new replica would.
+func (r *Runner) reviveReplica(replicaName string) error {
+	r.Lock()
+	replica, ok := r.CMDS[replicaName]
+	if !ok {
+		r.Unlock()
+		return fmt.Errorf("replica %s not found", replicaName)
+	}
+	if replica.State.Running() || replica.State == StateStopping {
+		r.Unlock()
+		return fmt.Errorf("replica %s is %s", replicaName, replica.State)
+	}
+	if replica.cancelRestart != nil {
+		close(replica.cancelRestart)
+		replica.cancelRestart = nil
+	}
+	replica.Restarts = 0
+	replica.RestartCount++
+	cfg, port := replica.Service, replica.Port
+	ctx := context.Background()
+	if svc, ok := r.services[cfg.Name]; ok {
+		ctx = svc.ctx
+	}
+	r.Unlock()
+
+	if !portFree(port) {
+		r.RLock()
+		newPort, err := r.allocatePort(cfg)
+		r.RUnlock()
+		if err != nil {
+			return fmt.Errorf("replica %s: port %d is taken: %w", replicaName, port, err)
+		}
+		log.Printf("[Sim] Port %d of replica %s is taken, moving it to %d", port, replicaName, newPort)
+		port = newPort
+	}
+
+	return r.startReplica(ctx, replicaName, cfg, port)
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner/runnertest"
+)
+
+func TestRestartReplica(t *testing.T) {
+	r := startTestService(t, runnertest.ShellService("sleeper", "exec sleep 60"))
+	before, _ := replicaStatus(r, "sleeper-1")
+
+	if err := r.RestartReplica("sleeper-1"); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := replicaStatus(r, "sleeper-1")
+	if !after.State.Running() || after.PID == before.PID {
+		t.Errorf("expected a new running process, before %+v, after %+v", before, after)
+	}
+	if after.Port != before.Port || after.Restarts != 1 {
+		t.Errorf("expected the same port and one restart, got %+v", after)
+	}
+
+	// A stopped replica is simply started again.
+	if err := r.StopReplica("sleeper-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RestartReplica("sleeper-1"); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := replicaStatus(r, "sleeper-1"); !st.State.Running() || st.Restarts != 2 {
+		t.Errorf("expected the stopped replica to run again, got %+v", st)
+	}
+
+	if err := r.RestartReplica("missing-1"); err == nil {
+		t.Error("expected restarting an unknown replica to fail")
+	}
+}
+
+func TestRestartService(t *testing.T) {
+	svc := runnertest.ShellService("sleeper", "exec sleep 60")
+	svc.Replicas = 2
+	r := startTestService(t, svc)
+	if err := r.StopReplica("sleeper-2"); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := replicaStatus(r, "sleeper-1")
+
+	if err := r.RestartService("sleeper"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sleeper-1", "sleeper-2"} {
+		if st, _ := replicaStatus(r, name); !st.State.Running() || st.Restarts != 1 {
+			t.Errorf("expected %s to be restarted, got %+v", name, st)
+		}
+	}
+	if st, _ := replicaStatus(r, "sleeper-1"); st.PID == before.PID {
+		t.Error("expected sleeper-1 to get a new process")
+	}
+
+	if err := r.RestartService("missing"); err == nil {
+		t.Error("expected restarting an unknown service to fail")
+	}
+}
+
+func TestRestartReplicaPortTaken(t *testing.T) {
+	r := startTestService(t, rangeService())
+	if err := r.StopReplica("ranged-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Something else grabs the stopped replica's port in the meantime.
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", 47310))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if err := r.RestartReplica("ranged-1"); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := replicaStatus(r, "ranged-1"); !st.State.Running() || st.Port != 47311 {
+		t.Errorf("expected ranged-1 to move to the next free port in its range, got %+v", st)
+	}
+}
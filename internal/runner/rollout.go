@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rollout rebuilds a service and replaces its active replicas in batches, the
+// way a Kubernetes rolling update would. Each batch takes up to max_surge new
+// replicas on fresh ports next to the old ones, and replaces up to
+// max_unavailable replicas in place. Every new replica has to become ready
+// before the next batch starts; if one doesn't, the rollout stops. A surged
+// replica that fails is removed and the old one it would have replaced is
+// kept, but a replica replaced in place has already lost its old process and
+// is left stopped. The remaining old replicas are left untouched.
+func (r *Runner) Rollout(serviceName string) error {
+	r.scaleMu.Lock()
+	defer r.scaleMu.Unlock()
+
+	r.RLock()
+	svc, ok := r.services[serviceName]
+	r.RUnlock()
+	if !ok {
+		return fmt.Errorf("service %s not found", serviceName)
+	}
+
+	if err := r.BuildService(svc.cfg); err != nil {
+		return fmt.Errorf("rollout of %s aborted before replacing any replica: %w", serviceName, err)
+	}
+
+	settings := svc.cfg.Rollout.WithDefaults()
+	old, _ := r.serviceReplicas(serviceName)
+	total := len(old)
+	log.Printf("[Sim] Rolling out %s: %d replicas (max_surge=%d, max_unavailable=%d)", serviceName, total, settings.MaxSurge, settings.MaxUnavailable)
+
+	replaced := 0
+	var stopped []string
+	for len(old) > 0 {
+		batch := min(settings.MaxSurge+settings.MaxUnavailable, len(old))
+		inPlace := min(settings.MaxUnavailable, batch)
+		current := old[:batch]
+		old = old[batch:]
+
+		// surged maps each new replica to the old one it replaces; replicas
+		// replaced in place map to themselves.
+		surged := make(map[string]string, batch)
+		var started []string
+		var failures []string
+
+		for _, name := range current[:inPlace] {
+			if err := r.StopReplica(name); err != nil {
+				log.Printf("[Sim] Rollout: %s", err)
+			}
+			if err := r.reviveReplica(name); err != nil {
+				failures = append(failures, err.Error())
+				stopped = append(stopped, name)
+				continue
+			}
+			surged[name] = name
+			started = append(started, name)
+		}
+		for _, oldName := range current[inPlace:] {
+			name, port, err := r.allocateReplica(svc.cfg)
+			if err == nil {
+				err = r.startReplica(svc.ctx, name, svc.cfg, port)
+			}
+			if err != nil {
+				failures = append(failures, err.Error())
+				old = append(old, oldName)
+				continue
+			}
+			surged[name] = oldName
+			started = append(started, name)
+		}
+
+		readyErrs := r.waitAllReady(svc, started, settings.ReadyTimeout)
+		for _, name := range started {
+			if err := readyErrs[name]; err != nil {
+				failures = append(failures, err.Error())
+				if surged[name] == name {
+					r.StopReplica(name)
+					stopped = append(stopped, name)
+				} else {
+					r.removeReplica(name)
+					old = append(old, surged[name])
+				}
+				continue
+			}
+			if oldName := surged[name]; oldName != name {
+				r.removeReplica(oldName)
+			}
+			replaced++
+			log.Printf("[Sim] Rollout of %s: %s ready (%d/%d)", serviceName, name, replaced, total)
+		}
+
+		if len(failures) > 0 {
+			msg := fmt.Sprintf("rollout of %s aborted after replacing %d/%d replicas: %s; %d old replicas left untouched",
+				serviceName, replaced, total, strings.Join(failures, "; "), len(old))
+			if len(stopped) > 0 {
+				msg += fmt.Sprintf(", %s stopped", strings.Join(stopped, ", "))
+			}
+			return errors.New(msg)
+		}
+	}
+
+	log.Printf("[Sim] Rollout of %s complete: %d replicas replaced", serviceName, replaced)
+	return nil
+}
+
+func (r *Runner) waitAllReady(svc *serviceRun, names []string, timeout time.Duration) map[string]error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error, len(names))
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			err := r.WaitReady(svc.ctx, name, timeout)
+			mu.Lock()
+			errs[name] = err
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return errs
+}
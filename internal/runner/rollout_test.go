@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner/runnertest"
+)
+
+// startRolloutService runs two replicas that log "ready" unless the returned
+// marker file exists, in which case they never become ready.
+func startRolloutService(t *testing.T, settings config.Rollout) (r *Runner, marker string) {
+	t.Helper()
+	marker = filepath.Join(t.TempDir(), "broken")
+	svc := runnertest.ShellService("rolling", `if [ -e "$MARKER" ]; then exec sleep 60; fi; echo ready; exec sleep 60`)
+	svc.Env["MARKER"] = marker
+	svc.Replicas = 2
+	svc.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "^ready$"}
+	settings.ReadyTimeout = 500 * time.Millisecond
+	svc.Rollout = settings
+
+	r = startTestService(t, svc)
+	waitForState(t, r, "rolling-1", StateReady)
+	waitForState(t, r, "rolling-2", StateReady)
+	return r, marker
+}
+
+// readyPIDs returns the PIDs of the service's ready replicas by name.
+func readyPIDs(r *Runner) map[string]int {
+	pids := make(map[string]int)
+	for _, st := range r.ListReplicas() {
+		if st.State == StateReady {
+			pids[st.Name] = st.PID
+		}
+	}
+	return pids
+}
+
+func TestRollout(t *testing.T) {
+	r, _ := startRolloutService(t, config.Rollout{MaxSurge: 1})
+	before := readyPIDs(r)
+
+	if err := r.Rollout("rolling"); err != nil {
+		t.Fatal(err)
+	}
+	after := readyPIDs(r)
+	if len(after) != 2 || len(r.ListReplicas()) != 2 {
+		t.Fatalf("expected 2 ready replicas and nothing else, got %+v", r.ListReplicas())
+	}
+	for name, pid := range before {
+		for _, newPID := range after {
+			if newPID == pid {
+				t.Errorf("%s (pid %d) was not replaced", name, pid)
+			}
+		}
+	}
+}
+
+func TestRolloutFailureInPlace(t *testing.T) {
+	r, marker := startRolloutService(t, config.Rollout{MaxUnavailable: 1})
+	before := readyPIDs(r)
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := r.Rollout("rolling")
+	if err == nil {
+		t.Fatal("expected the rollout to fail")
+	}
+	if !strings.Contains(err.Error(), "1 old replicas left untouched, rolling-1 stopped") {
+		t.Errorf("expected the error to report the stopped replica, got %v", err)
+	}
+	if st, _ := replicaStatus(r, "rolling-1"); st.State != StateStopped {
+		t.Errorf("expected rolling-1 to be stopped, got %s", st.State)
+	}
+	if pid := readyPIDs(r)["rolling-2"]; pid != before["rolling-2"] {
+		t.Errorf("expected rolling-2 to be untouched, pid %d became %d", before["rolling-2"], pid)
+	}
+}
+
+func TestRolloutFailureSurge(t *testing.T) {
+	r, marker := startRolloutService(t, config.Rollout{MaxSurge: 1})
+	before := readyPIDs(r)
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := r.Rollout("rolling")
+	if err == nil {
+		t.Fatal("expected the rollout to fail")
+	}
+	if !strings.Contains(err.Error(), "2 old replicas left untouched") || strings.Contains(err.Error(), "stopped") {
+		t.Errorf("expected the error to report both old replicas kept, got %v", err)
+	}
+	after := readyPIDs(r)
+	if len(after) != 2 || after["rolling-1"] != before["rolling-1"] || after["rolling-2"] != before["rolling-2"] {
+		t.Errorf("expected the old replicas to keep running, before %v, after %v", before, after)
+	}
+	if len(r.ListReplicas()) != 2 {
+		t.Errorf("expected the failed surge replica to be removed, got %+v", r.ListReplicas())
+	}
+}
@@ -0,0 +1,26 @@
+// Package runnertest provides services for tests that run real replicas.
+package runnertest
+
+import (
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+// ShellService returns a service with one replica that runs script with sh on
+// a dynamic port. It is never restarted and gets a second to stop.
+func ShellService(name, script string) config.Service {
+	return config.Service{
+		Name:    name,
+		Command: "sh",
+		// The script is passed through the environment since args have
+		// their $VARs expanded.
+		Args:          []string{"-c", "$SCRIPT"},
+		Env:           map[string]string{"SCRIPT": script},
+		PortMode:      config.PortModeDynamic,
+		Replicas:      1,
+		RestartPolicy: config.RestartNever,
+		StopSignal:    config.DefaultStopSignal,
+		StopTimeout:   time.Second,
+	}
+}
@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+// serviceRun is what the runner remembers about a started service so that
+// replicas can be added or removed later.
+type serviceRun struct {
+	ctx context.Context
+	cfg config.Service
+}
+
+// Scale starts or stops replicas of a started service until it has exactly
+// replicas active ones. Stopped or exited replicas are brought back first;
+// after that new replicas take the lowest free index and port in the
+// service's start_port..end_port range.
+func (r *Runner) Scale(serviceName string, replicas int) error {
+	r.scaleMu.Lock()
+	defer r.scaleMu.Unlock()
+
+	r.RLock()
+	svc, ok := r.services[serviceName]
+	r.RUnlock()
+	if !ok {
+		return fmt.Errorf("service %s not found", serviceName)
+	}
+
+	if replicas < 0 {
+		return fmt.Errorf("replica count must be >= 0")
+	}
+	if svc.cfg.PortMode != config.PortModeDynamic {
+		capacity := svc.cfg.EndPort - svc.cfg.StartPort + 1
+		if replicas > capacity {
+			return fmt.Errorf("service %s: port range (%d-%d) only fits %d replicas", serviceName, svc.cfg.StartPort, svc.cfg.EndPort, capacity)
+		}
+	}
+
+	active, inactive := r.serviceReplicas(serviceName)
+	switch {
+	case replicas > len(active):
+		need := replicas - len(active)
+		for _, name := range inactive {
+			if need == 0 {
+				break
+			}
+			if err := r.reviveReplica(name); err != nil {
+				return err
+			}
+			need--
+		}
+		for ; need > 0; need-- {
+			name, port, err := r.allocateReplica(svc.cfg)
+			if err != nil {
+				return err
+			}
+			if err := r.startReplica(svc.ctx, name, svc.cfg, port); err != nil {
+				return err
+			}
+		}
+	case replicas < len(active):
+		r.removeReplicas(active[replicas:])
+	default:
+		return nil
+	}
+
+	log.Printf("[Sim] Scaled %s from %d to %d replicas", serviceName, len(active), replicas)
+	return nil
+}
+
+// serviceReplicas splits the replicas of a service into active and inactive
+// ones. Active replicas are ordered the way they should be kept when scaling
+// down (running first, then by index); inactive ones by index.
+func (r *Runner) serviceReplicas(serviceName string) (active, inactive []string) {
+	r.RLock()
+	defer r.RUnlock()
+
+	type entry struct {
+		name    string
+		index   int
+		running bool
+	}
+	var activeEntries, inactiveEntries []entry
+	for name, replica := range r.CMDS {
+		if replica.Service.Name != serviceName {
+			continue
+		}
+		e := entry{
+			name:    name,
+			index:   replicaIndex(serviceName, name),
+			running: replica.State.Running(),
+		}
+		switch {
+		case replica.State.Active():
+			activeEntries = append(activeEntries, e)
+		case replica.State != StateStopping:
+			inactiveEntries = append(inactiveEntries, e)
+		}
+	}
+	sort.Slice(activeEntries, func(i, j int) bool {
+		if activeEntries[i].running != activeEntries[j].running {
+			return activeEntries[i].running
+		}
+		return activeEntries[i].index < activeEntries[j].index
+	})
+	sort.Slice(inactiveEntries, func(i, j int) bool {
+		return inactiveEntries[i].index < inactiveEntries[j].index
+	})
+
+	for _, e := range activeEntries {
+		active = append(active, e.name)
+	}
+	for _, e := range inactiveEntries {
+		inactive = append(inactive, e.name)
+	}
+	return active, inactive
+}
+
+// removeReplicas stops the named replicas concurrently and forgets them.
+func (r *Runner) removeReplicas(names []string) {
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			r.removeReplica(name)
+		}(name)
+	}
+	wg.Wait()
+}
+
+func (r *Runner) removeReplica(name string) {
+	if err := r.StopReplica(name); err != nil {
+		log.Printf("[Sim] Error stopping replica %s: %s", name, err)
+	}
+	r.Lock()
+	replica, ok := r.CMDS[name]
+	forget := ok && !replica.State.Active() && replica.State != StateStopping
+	if forget {
+		delete(r.CMDS, name)
+	}
+	files := r.logFiles
+	r.Unlock()
+
+	if forget && files != nil {
+		if err := files.Forget(name); err != nil {
+			log.Printf("[Sim] Failed to close log file for %s: %v", name, err)
+		}
+	}
+}
+
+// allocateReplica picks the lowest unused replica name for svc and a port
+// that is actually free, see allocatePort.
+func (r *Runner) allocateReplica(svc config.Service) (string, int, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	name := ""
+	for i := 1; name == ""; i++ {
+		candidate := fmt.Sprintf("%s-%d", svc.Name, i)
+		if _, taken := r.CMDS[candidate]; !taken {
+			name = candidate
+		}
+	}
+
+	port, err := r.allocatePort(svc)
+	if err != nil {
+		return "", 0, err
+	}
+	return name, port, nil
+}
+
+// allocatePort picks a free port for a replica of svc. In range mode that is
+// the lowest port in start_port..end_port that no replica of the service
+// holds and nothing else is listening on; ports of stopped replicas stay
+// reserved so they can come back on them. In dynamic mode the OS picks the
+// port. The caller must hold the runner's lock.
+func (r *Runner) allocatePort(svc config.Service) (int, error) {
+	if svc.PortMode == config.PortModeDynamic {
+		port, err := dynamicPort()
+		if err != nil {
+			return 0, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		return port, nil
+	}
+
+	usedPorts := make(map[int]bool)
+	for _, replica := range r.CMDS {
+		usedPorts[replica.Port] = true
+	}
+
+	for port := svc.StartPort; port <= svc.EndPort; port++ {
+		if usedPorts[port] {
+			continue
+		}
+		if !portFree(port) {
+			log.Printf("[Sim] Port %d is in use, skipping it for %s", port, svc.Name)
+			continue
+		}
+		return port, nil
+	}
+	return 0, fmt.Errorf("service %s: no free port in range %d-%d", svc.Name, svc.StartPort, svc.EndPort)
+}
+
+func portFree(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+func dynamicPort() (int, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, fmt.Errorf("allocating a dynamic port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func replicaIndex(serviceName, replicaName string) int {
+	idx, err := strconv.Atoi(strings.TrimPrefix(replicaName, serviceName+"-"))
+	if err != nil {
+		return 0
+	}
+	return idx
+}
@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner/runnertest"
+)
+
+// rangeService returns a long-running service with three ports to share.
+func rangeService() config.Service {
+	svc := runnertest.ShellService("ranged", "exec sleep 60")
+	svc.PortMode = config.PortModeRange
+	svc.StartPort = 47310
+	svc.EndPort = 47312
+	return svc
+}
+
+// replicaPorts maps each active replica of the runner to its port.
+func replicaPorts(r *Runner) map[string]int {
+	ports := make(map[string]int)
+	for _, st := range r.ListReplicas() {
+		if st.State.Active() {
+			ports[st.Name] = st.Port
+		}
+	}
+	return ports
+}
+
+func TestScaleRangeExhausted(t *testing.T) {
+	r := startTestService(t, rangeService())
+
+	err := r.Scale("ranged", 4)
+	if err == nil || !strings.Contains(err.Error(), "only fits 3 replicas") {
+		t.Fatalf("expected the port range to be exhausted, got %v", err)
+	}
+	if n := len(replicaPorts(r)); n != 1 {
+		t.Errorf("expected the failed scale to leave 1 replica, got %d", n)
+	}
+
+	if err := r.Scale("ranged", 3); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(replicaPorts(r)); n != 3 {
+		t.Errorf("expected 3 replicas, got %d", n)
+	}
+}
+
+func TestScaleReusesFreedPorts(t *testing.T) {
+	r := startTestService(t, rangeService())
+	if err := r.Scale("ranged", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Scale("ranged", 1); err != nil {
+		t.Fatal(err)
+	}
+	if ports := replicaPorts(r); len(ports) != 1 || ports["ranged-1"] != 47310 {
+		t.Fatalf("expected only ranged-1 on 47310 after scaling down, got %v", ports)
+	}
+	if r.HasReplica("ranged-2") || r.HasReplica("ranged-3") {
+		t.Error("expected scaled down replicas to be forgotten")
+	}
+
+	if err := r.Scale("ranged", 3); err != nil {
+		t.Fatal(err)
+	}
+	if ports := replicaPorts(r); len(ports) != 3 || ports["ranged-2"] != 47311 || ports["ranged-3"] != 47312 {
+		t.Errorf("expected ranged-2 and ranged-3 back on 47311 and 47312, got %v", ports)
+	}
+}
+
+func TestScaleWithStoppedReplicas(t *testing.T) {
+	r := startTestService(t, rangeService())
+	if err := r.Scale("ranged", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.StopReplica("ranged-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scaling down only removes active replicas; the stopped one keeps its
+	// record and its port.
+	if err := r.Scale("ranged", 1); err != nil {
+		t.Fatal(err)
+	}
+	if st, ok := replicaStatus(r, "ranged-2"); !ok || st.State != StateStopped {
+		t.Fatalf("expected ranged-2 to stay stopped, got %+v", st)
+	}
+	if r.HasReplica("ranged-3") {
+		t.Error("expected ranged-3 to be removed")
+	}
+
+	// Scaling up brings the stopped replica back on its port before adding
+	// new ones.
+	if err := r.Scale("ranged", 3); err != nil {
+		t.Fatal(err)
+	}
+	ports := replicaPorts(r)
+	if len(ports) != 3 || ports["ranged-2"] != 47311 || ports["ranged-3"] != 47312 {
+		t.Errorf("expected ranged-2 revived on 47311 and ranged-3 on 47312, got %v", ports)
+	}
+	if st, _ := replicaStatus(r, "ranged-2"); st.Restarts != 1 {
+		t.Errorf("expected the revived replica to count a restart, got %d", st.Restarts)
+	}
+}
+
+func TestScaleDownClosesLogFiles(t *testing.T) {
+	svc := runnertest.ShellService("chatty", "echo hello; exec sleep 60")
+	svc.Replicas = 2
+	svc.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "hello"}
+
+	r := NewRunner()
+	if err := r.SetLogDir(t.TempDir(), 1<<20, 1); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer r.ShutdownAll()
+	if err := r.StartService(ctx, svc); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, r, "chatty-1", StateReady)
+	waitForState(t, r, "chatty-2", StateReady)
+
+	if err := r.Scale("chatty", 1); err != nil {
+		t.Fatal(err)
+	}
+	r.logFiles.mu.Lock()
+	_, open := r.logFiles.files["chatty-2"]
+	r.logFiles.mu.Unlock()
+	if open {
+		t.Error("expected the removed replica's log file to be closed")
+	}
+}
+
+func TestDynamicPorts(t *testing.T) {
+	svc := runnertest.ShellService("dyn", "exec sleep 60")
+	svc.Replicas = 2
+	r := startTestService(t, svc)
+
+	ports := replicaPorts(r)
+	if len(ports) != 2 || ports["dyn-1"] == 0 || ports["dyn-1"] == ports["dyn-2"] {
+		t.Fatalf("expected two distinct OS-assigned ports, got %v", ports)
+	}
+
+	// Another process takes dyn-1's port while it is stopped; restarting
+	// moves it to a fresh port instead of failing to bind.
+	if err := r.StopReplica("dyn-1"); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", ports["dyn-1"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if err := r.RestartReplica("dyn-1"); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := replicaStatus(r, "dyn-1")
+	if !st.State.Running() || st.Port == 0 || st.Port == ports["dyn-1"] {
+		t.Errorf("expected dyn-1 to run on a new port instead of %d, got %+v", ports["dyn-1"], st)
+	}
+
+	// An untouched port is kept.
+	if err := r.RestartReplica("dyn-2"); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := replicaStatus(r, "dyn-2"); st.Port != ports["dyn-2"] {
+		t.Errorf("expected dyn-2 to keep port %d, got %d", ports["dyn-2"], st.Port)
+	}
+}
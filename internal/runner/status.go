@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"sort"
+	"time"
+)
+
+// ReplicaStatus is a point-in-time snapshot of a replica for display.
+type ReplicaStatus struct {
+	Name         string
+	Service      string
+	State        ReplicaState
+	PID          int
+	Port         int
+	StartedAt    time.Time
+	Restarts     int
+	LastExitCode int
+	LastError    string
+	Exited       bool
+}
+
+// Uptime is how long the replica's current process has been running, or 0
+// if it isn't running.
+func (s ReplicaStatus) Uptime() time.Duration {
+	switch s.State {
+	case StateStarting, StateReady, StateUnhealthy:
+		return time.Since(s.StartedAt)
+	default:
+		return 0
+	}
+}
+
+// ListReplicas returns the status of every known replica, sorted by service
+// and replica index. Ready replicas failing the load balancer's health
+// checks are reported as unhealthy.
+func (r *Runner) ListReplicas() []ReplicaStatus {
+	r.RLock()
+	defer r.RUnlock()
+
+	statuses := make([]ReplicaStatus, 0, len(r.CMDS))
+	for name, replica := range r.CMDS {
+		st := ReplicaStatus{
+			Name:         name,
+			Service:      replica.Service.Name,
+			State:        replica.State,
+			Port:         replica.Port,
+			StartedAt:    replica.StartedAt,
+			Restarts:     replica.RestartCount,
+			LastExitCode: replica.LastExitCode,
+			LastError:    replica.LastError,
+			Exited:       replica.Exited,
+		}
+		if replica.State.Running() && replica.Cmd != nil && replica.Cmd.Process != nil {
+			st.PID = replica.Cmd.Process.Pid
+		}
+		if st.State == StateReady && r.registry != nil && !r.registry.Healthy(st.Service, name) {
+			st.State = StateUnhealthy
+		}
+		statuses = append(statuses, st)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Service != statuses[j].Service {
+			return statuses[i].Service < statuses[j].Service
+		}
+		return replicaIndex(statuses[i].Service, statuses[i].Name) < replicaIndex(statuses[j].Service, statuses[j].Name)
+	})
+	return statuses
+}
+
+func (r *Runner) HasReplica(replicaName string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	_, ok := r.CMDS[replicaName]
+	return ok
+}
@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+)
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// WatchService polls the files matched by the service's watch globs and rolls
+// the service out once they have stopped changing for the debounce period.
+// onReload is called with the outcome of every reload; a failed build leaves
+// the running replicas untouched.
+func (r *Runner) WatchService(ctx context.Context, svc config.Service, onReload func(service string, err error)) {
+	if svc.Watch == nil {
+		return
+	}
+	w := svc.Watch.WithDefaults(svc)
+
+	snapshot := scanWatched(svc.WorkingDir, w.Paths)
+	log.Printf("[Sim] Watching %d files for %s", len(snapshot), svc.Name)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	var changedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := scanWatched(svc.WorkingDir, w.Paths)
+		if !sameFiles(snapshot, current) {
+			snapshot = current
+			changedAt = time.Now()
+			continue
+		}
+		if changedAt.IsZero() || time.Since(changedAt) < w.Debounce {
+			continue
+		}
+		changedAt = time.Time{}
+
+		log.Printf("[Sim] Source change detected for %s, reloading...", svc.Name)
+		err := r.Rollout(svc.Name)
+		if onReload != nil {
+			onReload(svc.Name, err)
+		}
+	}
+}
+
+// scanWatched stats every file matching patterns. A "**" path segment
+// matches any number of directories and a pattern may hold several; other
+// segments are matched with filepath.Match.
+func scanWatched(dir string, patterns []string) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	add := func(path string) {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return
+		}
+		files[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	for _, pattern := range patterns {
+		if dir != "" && !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		root, _, recursive := strings.Cut(pattern, "**")
+		if !recursive {
+			matches, _ := filepath.Glob(pattern)
+			for _, m := range matches {
+				add(m)
+			}
+			continue
+		}
+
+		// Walk everything below the first "**" and match whole paths, one
+		// segment at a time.
+		segments := splitPath(pattern)
+		filepath.WalkDir(filepath.Clean(root), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if matchSegments(segments, splitPath(path)) {
+				add(path)
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+func splitPath(path string) []string {
+	return strings.Split(filepath.ToSlash(path), "/")
+}
+
+// matchSegments reports whether the path segments match the pattern
+// segments, where a "**" segment matches zero or more path segments.
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func sameFiles(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, stamp := range a {
+		if other, ok := b[path]; !ok || other != stamp {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/config"
+	"github.com/joseph-gunnarsson/go-replicate-local/internal/runner/runnertest"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanWatched(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"main.go",
+		"internal/handlers/a.go",
+		"internal/api/handlers/b.go",
+		"internal/api/v1/handlers/c.go",
+		"internal/api/handlers/notes.md",
+		"internal/api/other.go",
+		"internal/api/v1/deep/handlers/x/d.go",
+	)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.go", []string{"main.go"}},
+		{"**/*.go", []string{
+			"internal/api/handlers/b.go",
+			"internal/api/other.go",
+			"internal/api/v1/deep/handlers/x/d.go",
+			"internal/api/v1/handlers/c.go",
+			"internal/handlers/a.go",
+			"main.go",
+		}},
+		{"internal/**/handlers/*.go", []string{
+			"internal/api/handlers/b.go",
+			"internal/api/v1/handlers/c.go",
+			"internal/handlers/a.go",
+		}},
+		{"internal/**/v1/**/*.go", []string{
+			"internal/api/v1/deep/handlers/x/d.go",
+			"internal/api/v1/handlers/c.go",
+		}},
+		{"internal/**/handlers/**/d.go", []string{"internal/api/v1/deep/handlers/x/d.go"}},
+		{"missing/**/*.go", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for path := range scanWatched(dir, []string{tt.pattern}) {
+			rel, _ := filepath.Rel(dir, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s:\n got %v\nwant %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestWatchServiceReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "src/app.conf")
+
+	svc := runnertest.ShellService("watched", "echo ready; exec sleep 60")
+	svc.WorkingDir = dir
+	svc.Readiness = &config.Readiness{Type: config.ProbeLog, Pattern: "^ready$"}
+	svc.Watch = &config.Watch{Paths: []string{"src/**/*.conf"}, Interval: 20 * time.Millisecond, Debounce: 60 * time.Millisecond}
+	r := startTestService(t, svc)
+	before := waitForState(t, r, "watched-1", StateReady)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan error, 1)
+	go r.WatchService(ctx, svc, func(service string, err error) {
+		reloads <- err
+	})
+
+	// Give the watcher time to take its first snapshot, then change the file
+	// twice in a row: the debounce folds both into one reload.
+	time.Sleep(100 * time.Millisecond)
+	writeFiles(t, dir, "src/app.conf", "src/nested/extra.conf")
+
+	select {
+	case err := <-reloads:
+		if err != nil {
+			t.Fatalf("reload failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the watched files changed")
+	}
+	if st := waitForState(t, r, "watched-1", StateReady); st.PID == before.PID {
+		t.Error("expected the replica to be replaced by the reload")
+	}
+
+	select {
+	case err := <-reloads:
+		t.Errorf("expected a single reload, got another (err %v)", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}